@@ -0,0 +1,357 @@
+package booking
+
+import (
+	"errors"
+	"time"
+
+	"taas-api/models"
+	"taas-api/scheduling"
+
+	"gorm.io/gorm"
+)
+
+// TemplateHorizonDays is how far past the first requested day weekly templates
+// are expanded when no last day is given, as for talents without a booking
+// horizon.
+const TemplateHorizonDays = 90
+
+// ErrInvalidRange is returned for ranges that do not end after they start.
+var ErrInvalidRange = errors.New("time range must end after it starts")
+
+// DayAvailability is the availability of a talent on one calendar day.
+type DayAvailability struct {
+	Date   time.Time // Midnight UTC
+	Ranges models.TimeRanges
+}
+
+// NormalizeRanges sorts ranges and merges those that overlap or touch. It fails
+// on a range that is invalid or does not end after it starts.
+func NormalizeRanges(ranges []models.TimeRange) (models.TimeRanges, error) {
+	intervals := make([]scheduling.Interval, 0, len(ranges))
+	for _, r := range ranges {
+		i, err := scheduling.Parse(r.StartTime, r.EndTime)
+		if errors.Is(err, scheduling.ErrEmptyInterval) {
+			return nil, ErrInvalidRange
+		}
+		if err != nil {
+			return nil, err
+		}
+		intervals = append(intervals, i)
+	}
+	return RangesOf(scheduling.Normalize(intervals)), nil
+}
+
+// Availability returns a talent's availability for every day from from to to
+// (midnight UTC, inclusive) that has any, in date order. It combines the weekly
+// templates, replaced by an override or removed by a blackout on single dates,
+// with the one-off AvailableTimeSlots rows. A blackout also hides one-off rows.
+// A zero to expands the templates TemplateHorizonDays past from and includes
+// every later one-off row.
+func Availability(db *gorm.DB, talentID string, from, to time.Time) ([]DayAvailability, error) {
+	var rows []models.AvailableTimeSlots
+	query := db.Where("talent_id = ? AND available_date >= ?", talentID, from)
+	if !to.IsZero() {
+		query = query.Where("available_date <= ?", to)
+	}
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	last := to
+	if last.IsZero() {
+		last = from.AddDate(0, 0, TemplateHorizonDays)
+		for _, row := range rows {
+			if row.AvailableDate.UTC().After(last) {
+				last = row.AvailableDate.UTC()
+			}
+		}
+	}
+
+	var templates []models.AvailabilityTemplate
+	if err := db.Where("talent_id = ? AND effective_from <= ? AND (effective_until IS NULL OR effective_until >= ?)", talentID, last, from).
+		Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	var exceptions []models.AvailabilityException
+	if err := db.Where("talent_id = ? AND date >= ? AND date <= ?", talentID, from, last).Find(&exceptions).Error; err != nil {
+		return nil, err
+	}
+
+	var dates []time.Time
+	for d := from.UTC(); !d.After(last); d = d.AddDate(0, 0, 1) {
+		dates = append(dates, d)
+	}
+	return expand(dates, templates, exceptions, rows), nil
+}
+
+// AvailabilityOn returns the availability of a talent on the given dates, keyed
+// by "2006-01-02"; see Availability.
+func AvailabilityOn(db *gorm.DB, talentID string, dates []time.Time) (map[string]models.TimeRanges, error) {
+	available := make(map[string]models.TimeRanges)
+	if len(dates) == 0 {
+		return available, nil
+	}
+	first, last := dates[0].UTC(), dates[0].UTC()
+	for _, d := range dates {
+		if d.UTC().Before(first) {
+			first = d.UTC()
+		}
+		if d.UTC().After(last) {
+			last = d.UTC()
+		}
+	}
+
+	var rows []models.AvailableTimeSlots
+	if err := db.Where("talent_id = ? AND available_date IN ?", talentID, dates).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	var templates []models.AvailabilityTemplate
+	if err := db.Where("talent_id = ? AND effective_from <= ? AND (effective_until IS NULL OR effective_until >= ?)", talentID, last, first).
+		Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	var exceptions []models.AvailabilityException
+	if err := db.Where("talent_id = ? AND date IN ?", talentID, dates).Find(&exceptions).Error; err != nil {
+		return nil, err
+	}
+
+	for _, day := range expand(dates, templates, exceptions, rows) {
+		available[day.Date.Format("2006-01-02")] = day.Ranges
+	}
+	return available, nil
+}
+
+// expand computes the availability of each date from already loaded templates,
+// exceptions and one-off rows. Invalid stored ranges are skipped.
+func expand(dates []time.Time, templates []models.AvailabilityTemplate, exceptions []models.AvailabilityException, rows []models.AvailableTimeSlots) []DayAvailability {
+	exceptionOn := make(map[string]models.AvailabilityException)
+	for _, e := range exceptions {
+		exceptionOn[e.Date.UTC().Format("2006-01-02")] = e
+	}
+	rowsOn := make(map[string]models.TimeRanges)
+	for _, row := range rows {
+		key := row.AvailableDate.UTC().Format("2006-01-02")
+		rowsOn[key] = append(rowsOn[key], row.AvailableSlots...)
+	}
+
+	var days []DayAvailability
+	for _, date := range dates {
+		date = date.UTC()
+		key := date.Format("2006-01-02")
+		var ranges models.TimeRanges
+		exception, excepted := exceptionOn[key]
+		switch {
+		case excepted && exception.Kind == models.ExceptionBlackout:
+			continue
+		case excepted:
+			ranges = append(ranges, exception.Slots...)
+		default:
+			for _, t := range templates {
+				if date.Before(t.EffectiveFrom.UTC()) || (t.EffectiveUntil != nil && date.After(t.EffectiveUntil.UTC())) {
+					continue
+				}
+				if t.Weekdays.Has(date.Weekday()) {
+					ranges = append(ranges, t.Slots...)
+				}
+			}
+		}
+		ranges = append(ranges, rowsOn[key]...)
+
+		set := SetOf(ranges)
+		if len(set) == 0 {
+			continue
+		}
+		days = append(days, DayAvailability{Date: date, Ranges: RangesOf(set)})
+	}
+	return days
+}
+
+// SubtractRanges returns the parts of ranges that are not in remove, sorted and
+// merged. Invalid ranges are ignored.
+func SubtractRanges(ranges, remove []models.TimeRange) models.TimeRanges {
+	return RangesOf(scheduling.Subtract(SetOf(ranges), SetOf(remove)))
+}
+
+// AvailabilityEdit is the outcome of EditAvailability.
+type AvailabilityEdit struct {
+	Records   []models.AvailableTimeSlots // Rows of the touched days that still have slots
+	Created   bool                        // A day had no row before
+	Conflicts []Conflict                  // Bookings in time the edit would remove
+}
+
+// EditAvailability removes and then adds one-off availability on the talent's
+// calendar days and stores each touched day sorted and merged; days left without
+// slots are deleted. Time of the weekly templates is changed with exceptions
+// instead and is unaffected. When bookings lie in time the edit takes away, they
+// are returned as conflicts and the caller must roll back. Call it inside a
+// transaction.
+func EditAvailability(tx *gorm.DB, talentID string, remove, add []Slot, timeZone string) (AvailabilityEdit, error) {
+	var edit AvailabilityEdit
+	if err := LockTalent(tx, talentID); err != nil {
+		return edit, err
+	}
+
+	var days []time.Time
+	removeOn := make(map[string][]models.TimeRange)
+	addOn := make(map[string][]models.TimeRange)
+	collect := func(slots []Slot, on map[string][]models.TimeRange) {
+		for _, s := range slots {
+			key := s.Date.Format("2006-01-02")
+			if _, ok := removeOn[key]; !ok {
+				if _, ok := addOn[key]; !ok {
+					days = append(days, s.Date)
+				}
+			}
+			on[key] = append(on[key], s.Range)
+		}
+	}
+	collect(remove, removeOn)
+	collect(add, addOn)
+	if len(days) == 0 {
+		return edit, nil
+	}
+
+	before, err := AvailabilityOn(tx, talentID, days)
+	if err != nil {
+		return edit, err
+	}
+
+	var rows []models.AvailableTimeSlots
+	if err := tx.Where("talent_id = ? AND available_date IN ?", talentID, days).Find(&rows).Error; err != nil {
+		return edit, err
+	}
+	rowOn := make(map[string]models.AvailableTimeSlots)
+	for _, row := range rows {
+		key := row.AvailableDate.UTC().Format("2006-01-02")
+		if existing, ok := rowOn[key]; ok {
+			// Fold duplicate rows of a day into the first one
+			row.AvailableSlots = append(existing.AvailableSlots, row.AvailableSlots...)
+			if err := tx.Delete(&existing).Error; err != nil {
+				return edit, err
+			}
+		}
+		rowOn[key] = row
+	}
+
+	for _, day := range days {
+		key := day.Format("2006-01-02")
+		row, exists := rowOn[key]
+		added, err := NormalizeRanges(addOn[key])
+		if err != nil {
+			return edit, err
+		}
+		slots := SubtractRanges(row.AvailableSlots, removeOn[key])
+		if slots, err = NormalizeRanges(append(slots, added...)); err != nil {
+			return edit, err
+		}
+
+		switch {
+		case len(slots) == 0 && exists:
+			if err := tx.Delete(&row).Error; err != nil {
+				return edit, err
+			}
+		case len(slots) == 0:
+		case exists:
+			row.AvailableSlots, row.TimeZone = slots, timeZone
+			if err := tx.Save(&row).Error; err != nil {
+				return edit, err
+			}
+			edit.Records = append(edit.Records, row)
+		default:
+			row = models.AvailableTimeSlots{TalentID: talentID, AvailableDate: day, AvailableSlots: slots, TimeZone: timeZone}
+			if err := tx.Create(&row).Error; err != nil {
+				return edit, err
+			}
+			edit.Created = true
+			edit.Records = append(edit.Records, row)
+		}
+	}
+
+	edit.Conflicts, err = BookedTimeRemoved(tx, talentID, days, before)
+	return edit, err
+}
+
+// BookedDates returns the dates from from to to (open-ended when to is zero) on
+// which the talent has active bookings: the dates a change of availability has
+// to check with BookedTimeRemoved.
+func BookedDates(tx *gorm.DB, talentID string, from, to time.Time) ([]time.Time, error) {
+	query := tx.Model(&models.BookingRequests{}).
+		Where("talent_id = ? AND booking_date >= ? AND status NOT IN ?", talentID, from, InactiveStatuses)
+	if !to.IsZero() {
+		query = query.Where("booking_date <= ?", to)
+	}
+	var dates []time.Time
+	if err := query.Distinct().Order("booking_date").Pluck("booking_date", &dates).Error; err != nil {
+		return nil, err
+	}
+	for i := range dates {
+		dates[i] = dates[i].UTC()
+	}
+	return dates, nil
+}
+
+// BookedTimeRemoved compares the talent's availability on the dates with before,
+// taken with AvailabilityOn ahead of a change, and returns the active bookings
+// that lie in time the change took away. The caller must roll the change back
+// when there are any; booked time cannot be removed.
+func BookedTimeRemoved(tx *gorm.DB, talentID string, dates []time.Time, before map[string]models.TimeRanges) ([]Conflict, error) {
+	if len(dates) == 0 {
+		return nil, nil
+	}
+	after, err := AvailabilityOn(tx, talentID, dates)
+	if err != nil {
+		return nil, err
+	}
+	var bookings []models.BookingRequests
+	if err := tx.Where("talent_id = ? AND booking_date IN ? AND status NOT IN ?", talentID, dates, InactiveStatuses).
+		Find(&bookings).Error; err != nil {
+		return nil, err
+	}
+	var conflicts []Conflict
+	for _, b := range bookings {
+		key := b.BookingDate.UTC().Format("2006-01-02")
+		removed := scheduling.Subtract(SetOf(before[key]), SetOf(after[key]))
+		for _, r := range b.BookedTime {
+			session, err := scheduling.Parse(r.StartTime, r.EndTime)
+			if err != nil {
+				continue
+			}
+			if removed.Overlaps(session) {
+				conflicts = append(conflicts, Conflict{Date: key, StartTime: r.StartTime, EndTime: r.EndTime, Reason: ConflictBooked})
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// ProtectBookedTime runs change, a change of the talent's templates or
+// exceptions, under the talent lock and returns the active bookings from from to
+// to (open-ended when to is zero) that lie in time it took away. Days before the
+// talent's today are not checked. Call it inside a transaction and roll back when
+// there are conflicts.
+func ProtectBookedTime(tx *gorm.DB, talentID string, from, to time.Time, now time.Time, change func(tx *gorm.DB) error) ([]Conflict, error) {
+	if err := LockTalent(tx, talentID); err != nil {
+		return nil, err
+	}
+	loc, err := TalentZone(tx, talentID)
+	if err != nil {
+		return nil, err
+	}
+	if today := Day(now, loc); from.Before(today) {
+		from = today
+	}
+	var dates []time.Time
+	if to.IsZero() || !to.Before(from) {
+		if dates, err = BookedDates(tx, talentID, from, to); err != nil {
+			return nil, err
+		}
+	}
+	before, err := AvailabilityOn(tx, talentID, dates)
+	if err != nil {
+		return nil, err
+	}
+	if err := change(tx); err != nil {
+		return nil, err
+	}
+	return BookedTimeRemoved(tx, talentID, dates, before)
+}
@@ -0,0 +1,123 @@
+package booking
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"taas-api/models"
+
+	"gorm.io/gorm"
+)
+
+// CancelResult summarizes the refund applied by Cancel.
+type CancelResult struct {
+	RefundPercent int                  `json:"refund_percent"`
+	RefundAmount  int                  `json:"refund_amount"`
+	PaymentStatus models.PaymentStatus `json:"payment_status"`
+}
+
+// SessionStart returns when the booked session begins: the earliest booked time
+// on the booking date, in the booking's time zone.
+func SessionStart(b models.BookingRequests) (time.Time, error) {
+	loc, err := LoadZone(b.TimeZone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var start time.Time
+	for _, r := range b.BookedTime {
+		t, _, err := Interval(b.BookingDate, r, loc)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if start.IsZero() || t.Before(start) {
+			start = t
+		}
+	}
+	if start.IsZero() {
+		return time.Time{}, errors.New("booking has no booked time")
+	}
+	return start, nil
+}
+
+// RefundPercent applies a cancellation policy to a user-initiated cancellation
+// at now for a session starting at start.
+func RefundPercent(policy models.CancellationPolicy, start, now time.Time) int {
+	if !now.Before(start) {
+		return 0
+	}
+	if len(policy) == 0 {
+		policy = models.DefaultCancellationPolicy
+	}
+	tiers := append(models.CancellationPolicy(nil), policy...)
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinHoursBefore > tiers[j].MinHoursBefore })
+
+	hoursBefore := start.Sub(now).Hours()
+	for _, tier := range tiers {
+		if hoursBefore >= float64(tier.MinHoursBefore) {
+			return tier.RefundPercent
+		}
+	}
+	return 0
+}
+
+// Cancel moves a booking to Cancelled and settles its payment. Users get the refund
+// of the card's cancellation policy; cancellations by the talent, an admin or the
+// system are always refunded in full. The price is charged per booked time range.
+// Cancelled bookings no longer occupy their slots.
+func Cancel(tx *gorm.DB, bookingID string, actor Actor, reason string, now time.Time) (models.BookingRequests, CancelResult, error) {
+	b, err := Transition(tx, bookingID, models.Cancelled, actor, reason)
+	if err != nil {
+		return b, CancelResult{}, err
+	}
+
+	// Soft-deleted cards still price their bookings; without the card row there
+	// is no price to refund from
+	var card models.ServiceCard
+	err = tx.Unscoped().Where("card_id = ?", b.CardID).First(&card).Error
+	cardMissing := errors.Is(err, gorm.ErrRecordNotFound)
+	if err != nil && !cardMissing {
+		return b, CancelResult{}, err
+	}
+
+	result := CancelResult{RefundPercent: 100}
+	if actor.Role == ActorUser {
+		start, err := SessionStart(b)
+		if err != nil {
+			return b, CancelResult{}, err
+		}
+		result.RefundPercent = RefundPercent(card.CancellationPolicy, start, now)
+	}
+
+	switch {
+	case b.PaymentStatus == models.Paid && cardMissing:
+		// The payment stays recorded as Paid so it can be settled by hand
+		result.RefundPercent = 0
+		result.PaymentStatus = models.Paid
+	case b.PaymentStatus == models.Paid:
+		result.RefundAmount = card.Price * len(b.BookedTime) * result.RefundPercent / 100
+		switch {
+		case result.RefundPercent == 100:
+			result.PaymentStatus = models.Refunded
+		case result.RefundAmount > 0:
+			result.PaymentStatus = models.PartiallyRefunded
+		default:
+			result.PaymentStatus = models.Paid
+		}
+	default:
+		// Nothing was charged, so nothing is refunded
+		result.PaymentStatus = models.Voided
+	}
+
+	b.PaymentStatus = result.PaymentStatus
+	b.RefundAmount = result.RefundAmount
+	b.CancelledAt = &now
+	b.CancelledBy = actor.Role
+	err = tx.Model(&b).Updates(map[string]interface{}{
+		"payment_status": b.PaymentStatus,
+		"refund_amount":  b.RefundAmount,
+		"cancelled_at":   now,
+		"cancelled_by":   actor.Role,
+	}).Error
+	return b, result, err
+}
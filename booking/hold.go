@@ -0,0 +1,59 @@
+package booking
+
+import (
+	"log"
+	"time"
+
+	"taas-api/models"
+
+	"gorm.io/gorm"
+)
+
+// Hold lifetimes accepted by the hold API.
+const (
+	DefaultHoldDuration = 10 * time.Minute
+	MaxHoldDuration     = 30 * time.Minute
+)
+
+// ActiveHolds scopes a query on BookingHold to holds that still block time.
+func ActiveHolds(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Where("released_at IS NULL AND expires_at > ?", now)
+}
+
+// ReleaseExpiredHolds marks holds past their expiry as released.
+func ReleaseExpiredHolds(db *gorm.DB) (int64, error) {
+	now := time.Now()
+	result := db.Model(&models.BookingHold{}).
+		Where("released_at IS NULL AND expires_at <= ?", now).
+		Update("released_at", now)
+	return result.RowsAffected, result.Error
+}
+
+// StartHoldSweeper releases expired holds every interval in the background.
+// Expired holds already stop blocking time on their own; the sweeper keeps the
+// table state accurate for listings and hands expired waitlist offers to the
+// next user in line, passing new offers to notify.
+func StartHoldSweeper(db *gorm.DB, interval time.Duration, notify func([]Offer)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			offers, err := ExpireWaitlistOffers(db, time.Now())
+			if err != nil {
+				log.Printf("Error expiring waitlist offers: %v\n", err)
+			}
+			if len(offers) > 0 && notify != nil {
+				notify(offers)
+			}
+
+			released, err := ReleaseExpiredHolds(db)
+			if err != nil {
+				log.Printf("Error releasing expired booking holds: %v\n", err)
+				continue
+			}
+			if released > 0 {
+				log.Printf("Released %d expired booking holds\n", released)
+			}
+		}
+	}()
+}
@@ -0,0 +1,299 @@
+package booking
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"taas-api/models"
+	"taas-api/scheduling"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"storj.io/common/uuid"
+)
+
+// RescheduleApprovalWindow is how long a reschedule waiting for approval keeps
+// its new time on hold. The hold never outlasts the start of the current session.
+const RescheduleApprovalWindow = 48 * time.Hour
+
+var (
+	// ErrNotReschedulable is returned for bookings that are finished, cancelled or already started.
+	ErrNotReschedulable = errors.New("booking can no longer be rescheduled")
+	// ErrRescheduleLimit is returned when the card's reschedule limit is used up.
+	ErrRescheduleLimit = errors.New("reschedule limit reached")
+	// ErrNoPendingReschedule is returned when there is no reschedule to decide on.
+	ErrNoPendingReschedule = errors.New("no pending reschedule request")
+	// ErrRescheduleExpired is returned when the hold of a pending reschedule ran out.
+	ErrRescheduleExpired = errors.New("reschedule request expired")
+	// ErrSessionMismatch is returned when a new time does not keep the booked
+	// sessions: as many ranges as the booking has, each as long as the card's.
+	ErrSessionMismatch = errors.New("new time must keep the booked sessions")
+)
+
+// reschedulable are the statuses in which a booking may still be moved.
+var reschedulable = []models.BookingStatus{models.Requested, models.Accepted, models.Scheduled}
+
+// RescheduleOutcome is the result of a reschedule attempt. Conflicts is set when
+// the new time is not free; Pending is set when the move waits for approval.
+type RescheduleOutcome struct {
+	Booking   models.BookingRequests    `json:"booking"`
+	Pending   *models.RescheduleRequest `json:"pending,omitempty"`
+	Conflicts []Conflict                `json:"conflicts,omitempty"`
+}
+
+// NeedsApproval reports whether a reschedule by actor waits for the talent.
+func NeedsApproval(card models.ServiceCard, actor Actor) bool {
+	return card.RescheduleApproval && actor.Role == ActorUser
+}
+
+// Reschedule moves a booking to a new date and time in one transaction: the new
+// time is checked against availability, bookings and holds of other users, and
+// the booking row is updated only if it is free. When the card requires approval
+// for moves by the booker, the new time is put on hold and a pending
+// RescheduleRequest is returned instead.
+func Reschedule(tx *gorm.DB, bookingID string, newDate time.Time, newTime models.TimeRanges, actor Actor, reason string, now time.Time) (RescheduleOutcome, error) {
+	b, card, err := lockForReschedule(tx, bookingID, now)
+	if err != nil {
+		return RescheduleOutcome{Booking: b}, err
+	}
+	if err := checkSessions(b, card, newTime); err != nil {
+		return RescheduleOutcome{Booking: b}, err
+	}
+
+	conflicts, err := CheckSlots(tx, b.TalentID, b.CardID, b.UserID, slotsOf(newDate, newTime), b.BookingID)
+	if err != nil || len(conflicts) > 0 {
+		return RescheduleOutcome{Booking: b, Conflicts: conflicts}, err
+	}
+
+	if NeedsApproval(card, actor) {
+		pending, err := requestReschedule(tx, b, newDate, newTime, actor, reason, now)
+		return RescheduleOutcome{Booking: b, Pending: pending}, err
+	}
+
+	b, err = applyReschedule(tx, b, newDate, newTime, actor, reason)
+	return RescheduleOutcome{Booking: b}, err
+}
+
+// DecideReschedule approves or rejects the pending reschedule of a booking.
+func DecideReschedule(tx *gorm.DB, bookingID string, approve bool, actor Actor, now time.Time) (RescheduleOutcome, error) {
+	var pending models.RescheduleRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("booking_id = ? AND status = ?", bookingID, models.ReschedulePending).
+		First(&pending).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return RescheduleOutcome{}, ErrNoPendingReschedule
+		}
+		return RescheduleOutcome{}, err
+	}
+
+	decide := func(status string) error {
+		if err := tx.Model(&models.BookingHold{}).
+			Where("hold_id = ? AND released_at IS NULL", pending.HoldID).
+			Update("released_at", now).Error; err != nil {
+			return err
+		}
+		pending.Status = status
+		pending.DecidedBy = actor.ID
+		pending.DecidedAt = &now
+		return tx.Model(&pending).Updates(map[string]interface{}{
+			"status": status, "decided_by": actor.ID, "decided_at": now,
+		}).Error
+	}
+
+	if !approve {
+		err := decide(models.RescheduleRejected)
+		return RescheduleOutcome{Pending: &pending}, err
+	}
+
+	var active int64
+	if err := ActiveHolds(tx.Model(&models.BookingHold{}), now).
+		Where("hold_id = ?", pending.HoldID).Count(&active).Error; err != nil {
+		return RescheduleOutcome{}, err
+	}
+	if active == 0 {
+		if err := decide(models.RescheduleExpired); err != nil {
+			return RescheduleOutcome{}, err
+		}
+		return RescheduleOutcome{Pending: &pending}, ErrRescheduleExpired
+	}
+
+	b, card, err := lockForReschedule(tx, bookingID, now)
+	if err != nil {
+		return RescheduleOutcome{Booking: b}, err
+	}
+	if err := checkSessions(b, card, pending.NewTime); err != nil {
+		return RescheduleOutcome{Booking: b}, err
+	}
+	// The hold belongs to the booker, so it does not conflict with itself
+	conflicts, err := CheckSlots(tx, b.TalentID, b.CardID, b.UserID, slotsOf(pending.NewDate, pending.NewTime), b.BookingID)
+	if err != nil || len(conflicts) > 0 {
+		return RescheduleOutcome{Booking: b, Conflicts: conflicts}, err
+	}
+	if err := decide(models.RescheduleApproved); err != nil {
+		return RescheduleOutcome{}, err
+	}
+	b, err = applyReschedule(tx, b, pending.NewDate, pending.NewTime, actor, pending.Reason)
+	return RescheduleOutcome{Booking: b, Pending: &pending}, err
+}
+
+// lockForReschedule locks the talent and the booking and checks that the booking
+// may still be moved.
+func lockForReschedule(tx *gorm.DB, bookingID string, now time.Time) (models.BookingRequests, models.ServiceCard, error) {
+	var b models.BookingRequests
+	if err := tx.Where("booking_id = ?", bookingID).First(&b).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return b, models.ServiceCard{}, ErrNotFound
+		}
+		return b, models.ServiceCard{}, err
+	}
+	// Talent first, then the booking, the same order as new reservations
+	if err := LockTalent(tx, b.TalentID); err != nil {
+		return b, models.ServiceCard{}, err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("booking_id = ?", bookingID).First(&b).Error; err != nil {
+		return b, models.ServiceCard{}, err
+	}
+
+	allowed := false
+	for _, s := range reschedulable {
+		if b.Status == s {
+			allowed = true
+		}
+	}
+	if start, err := SessionStart(b); err != nil || !now.Before(start) {
+		allowed = false
+	}
+	if !allowed {
+		return b, models.ServiceCard{}, ErrNotReschedulable
+	}
+
+	var card models.ServiceCard
+	if err := tx.Unscoped().Where("card_id = ?", b.CardID).First(&card).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return b, card, err
+	}
+	if b.RescheduleCount >= card.RescheduleLimit() {
+		return b, card, ErrRescheduleLimit
+	}
+	return b, card, nil
+}
+
+// checkSessions checks that a new time keeps the sessions paid for: as many
+// ranges as the booking has, each as long as a session of its card. Without the
+// card only the number of ranges is checked.
+func checkSessions(b models.BookingRequests, card models.ServiceCard, newTime models.TimeRanges) error {
+	if len(newTime) != len(b.BookedTime) {
+		return fmt.Errorf("%w: %d time slots given for %d booked", ErrSessionMismatch, len(newTime), len(b.BookedTime))
+	}
+	for _, r := range newTime {
+		interval, err := scheduling.Parse(r.StartTime, r.EndTime)
+		if err != nil {
+			return err
+		}
+		if card.Duration > 0 && interval.Len() != card.Duration {
+			return fmt.Errorf("%w: time slot %s must be %d minutes long", ErrSessionMismatch, interval, card.Duration)
+		}
+	}
+	return nil
+}
+
+// requestReschedule replaces any pending request of the booking with a new one
+// and holds the new time for the booker.
+func requestReschedule(tx *gorm.DB, b models.BookingRequests, newDate time.Time, newTime models.TimeRanges, actor Actor, reason string, now time.Time) (*models.RescheduleRequest, error) {
+	var previous []models.RescheduleRequest
+	if err := tx.Where("booking_id = ? AND status = ?", b.BookingID, models.ReschedulePending).Find(&previous).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range previous {
+		if err := tx.Model(&models.BookingHold{}).Where("hold_id = ? AND released_at IS NULL", p.HoldID).
+			Update("released_at", now).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&p).Update("status", models.RescheduleWithdrawn).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	holdID, err := uuid.New()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := now.Add(RescheduleApprovalWindow)
+	if start, err := SessionStart(b); err == nil && start.Before(expiresAt) {
+		expiresAt = start
+	}
+	hold := models.BookingHold{
+		HoldID:      holdID.String(),
+		Kind:        models.HoldReschedule,
+		UserID:      b.UserID,
+		TalentID:    b.TalentID,
+		CardID:      b.CardID,
+		CardTitle:   b.CardTitle,
+		SessionType: b.SessionType,
+		BookingDate: newDate,
+		HeldTime:    newTime,
+		ExpiresAt:   expiresAt,
+	}
+	if err := tx.Create(&hold).Error; err != nil {
+		return nil, err
+	}
+
+	pending := models.RescheduleRequest{
+		BookingID:   b.BookingID,
+		RequestedBy: actor.ID,
+		NewDate:     newDate,
+		NewTime:     newTime,
+		Reason:      reason,
+		HoldID:      hold.HoldID,
+		Status:      models.ReschedulePending,
+	}
+	if err := tx.Create(&pending).Error; err != nil {
+		return nil, err
+	}
+	return &pending, nil
+}
+
+// applyReschedule writes the new time to the booking and records the move in the
+// history (a row whose status does not change).
+func applyReschedule(tx *gorm.DB, b models.BookingRequests, newDate time.Time, newTime models.TimeRanges, actor Actor, reason string) (models.BookingRequests, error) {
+	note := fmt.Sprintf("Rescheduled from %s %s to %s %s",
+		b.BookingDate.Format("2006-01-02"), formatRanges(b.BookedTime),
+		newDate.Format("2006-01-02"), formatRanges(newTime))
+	if reason != "" {
+		note += ": " + reason
+	}
+
+	b.BookingDate = newDate
+	b.BookedTime = newTime
+	b.RescheduleCount++
+	b.UpdatedAt = time.Now()
+	if err := tx.Model(&b).Updates(map[string]interface{}{
+		"booking_date":     b.BookingDate,
+		"booked_time":      b.BookedTime,
+		"reschedule_count": b.RescheduleCount,
+		"updated_at":       b.UpdatedAt,
+	}).Error; err != nil {
+		return b, err
+	}
+	return b, RecordTransition(tx, b.BookingID, b.Status, b.Status, actor, note)
+}
+
+func slotsOf(date time.Time, ranges models.TimeRanges) []Slot {
+	slots := make([]Slot, 0, len(ranges))
+	for _, r := range ranges {
+		slots = append(slots, Slot{Date: date, Range: r})
+	}
+	return slots
+}
+
+func formatRanges(ranges models.TimeRanges) string {
+	s := ""
+	for i, r := range ranges {
+		if i > 0 {
+			s += ","
+		}
+		s += r.StartTime + "-" + r.EndTime
+	}
+	return s
+}
@@ -0,0 +1,394 @@
+package booking
+
+import (
+	"errors"
+	"time"
+
+	"taas-api/models"
+	"taas-api/scheduling"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrTalentNotFound is returned when a reservation targets an unknown talent.
+var ErrTalentNotFound = errors.New("talent not found")
+
+// Reasons reported in a Conflict.
+const (
+	ConflictUnavailable = "unavailable" // Outside the talent's published availability
+	ConflictBooked      = "booked"      // Overlaps an existing booking
+	ConflictFull        = "full"        // Every seat of the group session is taken
+	ConflictDuplicate   = "duplicate"   // Overlaps another slot of the same request
+)
+
+// InactiveStatuses are booking statuses that no longer occupy their time.
+var InactiveStatuses = []models.BookingStatus{models.Declined, models.Cancelled}
+
+// Slot is one time range on one day that a booking wants to occupy.
+type Slot struct {
+	Date  time.Time // Midnight of the booking date
+	Range models.TimeRange
+}
+
+// Conflict describes why a requested slot cannot be reserved.
+type Conflict struct {
+	Date      string `json:"booking_date"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	Reason    string `json:"reason"`
+}
+
+// LockTalent serializes reservations for one talent by locking its registration
+// row until the surrounding transaction ends.
+func LockTalent(tx *gorm.DB, talentID string) error {
+	var registrations []models.TalentRegistration
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("talent_id = ?", talentID).Find(&registrations).Error; err != nil {
+		return err
+	}
+	if len(registrations) == 0 {
+		return ErrTalentNotFound
+	}
+	return nil
+}
+
+// Occupancy is time of a talent taken by a booking or by another user's hold.
+type Occupancy struct {
+	Range        models.TimeRange
+	CardID       string
+	UserID       string
+	BufferBefore int // Minutes kept free before the session, from its card's rules
+	BufferAfter  int // Minutes kept free after the session
+}
+
+// Occupied returns the occupancy per date ("2006-01-02") of a talent: active
+// bookings except ignoreBookingIDs, and active holds of users other than
+// exceptHoldsOf. Each occupancy carries the buffers of its card.
+func Occupied(tx *gorm.DB, talentID string, dates []time.Time, exceptHoldsOf string, ignoreBookingIDs ...string) (map[string][]Occupancy, error) {
+	rules, err := TalentRules(tx, talentID)
+	if err != nil {
+		return nil, err
+	}
+	occupied, err := occupiedByTalent(tx, map[string]Rules{talentID: rules}, dates, exceptHoldsOf, time.Now(), ignoreBookingIDs...)
+	if err != nil {
+		return nil, err
+	}
+	if occupied[talentID] == nil {
+		return make(map[string][]Occupancy), nil
+	}
+	return occupied[talentID], nil
+}
+
+// occupiedByTalent is Occupied for every talent of talentRules at once, keyed by
+// talent and date, with one query for bookings, holds and their cards each.
+func occupiedByTalent(tx *gorm.DB, talentRules map[string]Rules, dates []time.Time, exceptHoldsOf string, now time.Time, ignoreBookingIDs ...string) (map[string]map[string][]Occupancy, error) {
+	occupied := make(map[string]map[string][]Occupancy)
+	if len(talentRules) == 0 || len(dates) == 0 {
+		return occupied, nil
+	}
+	talentIDs := make([]string, 0, len(talentRules))
+	for id := range talentRules {
+		talentIDs = append(talentIDs, id)
+	}
+	add := func(talentID string, date time.Time, o Occupancy) {
+		if occupied[talentID] == nil {
+			occupied[talentID] = make(map[string][]Occupancy)
+		}
+		key := date.UTC().Format("2006-01-02")
+		occupied[talentID][key] = append(occupied[talentID][key], o)
+	}
+
+	var bookings []models.BookingRequests
+	query := tx.Where("talent_id IN ? AND booking_date IN ? AND status NOT IN ?", talentIDs, dates, InactiveStatuses)
+	if len(ignoreBookingIDs) > 0 {
+		query = query.Where("booking_id NOT IN ?", ignoreBookingIDs)
+	}
+	if err := query.Find(&bookings).Error; err != nil {
+		return nil, err
+	}
+	for _, b := range bookings {
+		for _, r := range b.BookedTime {
+			add(b.TalentID, b.BookingDate, Occupancy{Range: r, CardID: b.CardID, UserID: b.UserID})
+		}
+	}
+
+	var holds []models.BookingHold
+	if err := ActiveHolds(tx, now).
+		Where("talent_id IN ? AND booking_date IN ? AND user_id <> ?", talentIDs, dates, exceptHoldsOf).
+		Find(&holds).Error; err != nil {
+		return nil, err
+	}
+	for _, h := range holds {
+		for _, r := range h.HeldTime {
+			add(h.TalentID, h.BookingDate, Occupancy{Range: r, CardID: h.CardID, UserID: h.UserID})
+		}
+	}
+
+	return occupied, withBuffers(tx, talentRules, occupied)
+}
+
+// withBuffers sets the buffers of each occupancy, per talent and date, from the
+// rules of its card: the talent's rules with the card's overrides.
+func withBuffers(tx *gorm.DB, talentRules map[string]Rules, occupied map[string]map[string][]Occupancy) error {
+	var cardIDs []string
+	seen := make(map[string]bool)
+	for _, byDate := range occupied {
+		for _, list := range byDate {
+			for _, o := range list {
+				if !seen[o.CardID] {
+					seen[o.CardID] = true
+					cardIDs = append(cardIDs, o.CardID)
+				}
+			}
+		}
+	}
+	cards := make(map[string]models.ServiceCard, len(cardIDs))
+	if len(cardIDs) > 0 {
+		var found []models.ServiceCard
+		if err := tx.Unscoped().Where("card_id IN ?", cardIDs).Find(&found).Error; err != nil {
+			return err
+		}
+		for _, card := range found {
+			cards[card.CardID] = card
+		}
+	}
+	for talentID, byDate := range occupied {
+		for _, list := range byDate {
+			for i := range list {
+				rules := talentRules[talentID]
+				if card, ok := cards[list[i].CardID]; ok {
+					rules = rules.WithCard(card)
+				}
+				list[i].BufferBefore, list[i].BufferAfter = rules.BufferBefore, rules.BufferAfter
+			}
+		}
+	}
+	return nil
+}
+
+// withTalentBuffers is withBuffers for the occupancy of one talent.
+func withTalentBuffers(tx *gorm.DB, talentID string, occupied map[string][]Occupancy) error {
+	rules, err := TalentRules(tx, talentID)
+	if err != nil {
+		return err
+	}
+	return withBuffers(tx, map[string]Rules{talentID: rules}, map[string]map[string][]Occupancy{talentID: occupied})
+}
+
+// TiledSlot is a session of a card tiled into a day's availability.
+type TiledSlot struct {
+	scheduling.Interval           // Minutes on the talent's wall clock
+	Start               time.Time // When the session begins
+	End                 time.Time // When the session ends
+	SeatsLeft           int
+	Bookable            bool // Seats are left, the session is exactly the card's length and the rules allow booking it now
+}
+
+// TileDay tiles a day's availability with sessions of duration minutes kept apart
+// by the buffers of rules, and tells for each how many seats of the card are left
+// for userID and whether it can be booked at now. Sessions that a daylight saving
+// change makes longer or shorter than the card are not bookable. Every view of
+// bookable slots, for one talent or a search over many, goes through here.
+func TileDay(day DayAvailability, duration int, cardID, userID string, capacity int, rules Rules, occupied []Occupancy, loc *time.Location, now time.Time) []TiledSlot {
+	var slots []TiledSlot
+	for _, slot := range scheduling.Tile(SetOf(day.Ranges), scheduling.TileOptions{Duration: duration, Gap: rules.Gap()}) {
+		t := TiledSlot{
+			Interval:  slot,
+			Start:     At(day.Date, slot.Start, loc),
+			End:       At(day.Date, slot.End, loc),
+			SeatsLeft: SeatsLeft(slot.Start, slot.End, cardID, userID, capacity, rules, occupied),
+		}
+		t.Bookable = t.SeatsLeft > 0 && t.End.Sub(t.Start) == time.Duration(duration)*time.Minute &&
+			rules.Check(day.Date, slot.Start, now, loc) == ""
+		slots = append(slots, t)
+	}
+	return slots
+}
+
+// SeatsLeft returns how many more users can book [start, end) (minutes after
+// midnight) of a card with the given capacity and rules. A session of the same
+// card at exactly the same time takes one seat, or all of them if userID is
+// already in it. Any other time that comes closer than the buffers of both
+// sessions, e.g. another card or the same card at a different time, leaves no
+// seat.
+func SeatsLeft(start, end int, cardID string, userID string, capacity int, rules Rules, occupied []Occupancy) int {
+	seats := capacity
+	for _, o := range occupied {
+		os, oe, err := ClockRange(o.Range)
+		if err != nil {
+			continue
+		}
+		if o.CardID == cardID && os == start && oe == end {
+			if userID != "" && o.UserID == userID {
+				return 0
+			}
+			seats--
+			continue
+		}
+		if buffered(start, end, rules, os, oe, o) {
+			return 0
+		}
+	}
+	if seats < 0 {
+		return 0
+	}
+	return seats
+}
+
+// CardCapacity returns the number of seats per session of a card; unknown cards
+// have one seat.
+func CardCapacity(tx *gorm.DB, cardID string) (int, error) {
+	var card models.ServiceCard
+	if err := tx.Unscoped().Where("card_id = ?", cardID).First(&card).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 1, nil
+		}
+		return 0, err
+	}
+	if card.Capacity < 1 {
+		return 1, nil
+	}
+	return card.Capacity, nil
+}
+
+// CheckSlots reports every slot of a card that lies outside the talent's
+// availability, is taken by a booking or another user's hold (group sessions
+// conflict only once full) including the buffers around sessions, breaks the
+// minimum notice or booking horizon, or overlaps another slot of the same request. Holds
+// of userID itself and the bookings in ignoreBookingIDs (e.g. the booking being
+// moved) do not conflict. Call it after LockTalent so the answer stays valid
+// until commit.
+func CheckSlots(tx *gorm.DB, talentID string, cardID string, userID string, slots []Slot, ignoreBookingIDs ...string) ([]Conflict, error) {
+	var dates []time.Time
+	seen := make(map[string]bool)
+	for _, s := range slots {
+		key := s.Date.Format("2006-01-02")
+		if !seen[key] {
+			seen[key] = true
+			dates = append(dates, s.Date)
+		}
+	}
+
+	available, err := AvailabilityOn(tx, talentID, dates)
+	if err != nil {
+		return nil, err
+	}
+
+	occupied, err := Occupied(tx, talentID, dates, userID, ignoreBookingIDs...)
+	if err != nil {
+		return nil, err
+	}
+	capacity, err := CardCapacity(tx, cardID)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := CardRules(tx, talentID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := TalentZone(tx, talentID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	var conflicts []Conflict
+	for i, s := range slots {
+		key := s.Date.Format("2006-01-02")
+		start, end, err := ClockRange(s.Range)
+		if err != nil {
+			return nil, err
+		}
+		conflict := func(reason string) {
+			conflicts = append(conflicts, Conflict{Date: key, StartTime: s.Range.StartTime, EndTime: s.Range.EndTime, Reason: reason})
+		}
+
+		if reason := rules.Check(s.Date, start, now, loc); reason != "" {
+			conflict(reason)
+			continue
+		}
+		if !SetOf(available[key]).Covers(scheduling.Interval{Start: start, End: end}) {
+			conflict(ConflictUnavailable)
+			continue
+		}
+		if SeatsLeft(start, end, cardID, userID, capacity, rules, occupied[key]) == 0 {
+			conflict(occupiedReason(start, end, cardID, userID, rules, occupied[key]))
+			continue
+		}
+		for _, other := range slots[:i] {
+			if other.Date.Format("2006-01-02") != key {
+				continue
+			}
+			if SetOf(models.TimeRanges{other.Range}).Overlaps(scheduling.Interval{Start: start, End: end}) {
+				conflict(ConflictDuplicate)
+				break
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// occupiedReason explains why SeatsLeft found no seat: a full group session or
+// time taken otherwise.
+func occupiedReason(start, end int, cardID string, userID string, rules Rules, occupied []Occupancy) string {
+	for _, o := range occupied {
+		os, oe, err := ClockRange(o.Range)
+		if err != nil {
+			continue
+		}
+		if o.CardID == cardID && os == start && oe == end {
+			if o.UserID == userID {
+				return ConflictBooked
+			}
+			continue
+		}
+		if buffered(start, end, rules, os, oe, o) {
+			return ConflictBooked
+		}
+	}
+	return ConflictFull
+}
+
+// buffered reports whether a session [start, end) with the given rules and the
+// occupied [os, oe) overlap once each is widened by its buffers.
+func buffered(start, end int, rules Rules, os, oe int, o Occupancy) bool {
+	session := scheduling.Interval{Start: start, End: end}.Widen(rules.BufferBefore, rules.BufferAfter)
+	return session.Overlaps(scheduling.Interval{Start: os, End: oe}.Widen(o.BufferBefore, o.BufferAfter))
+}
+
+// ClockRange converts an "HH:MM" range to minutes after midnight. The end may be
+// "24:00" for ranges that last until the end of the day. Unlike scheduling.Parse
+// it does not require the range to end after it starts.
+func ClockRange(r models.TimeRange) (int, int, error) {
+	start, err := scheduling.ParseClock(r.StartTime)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := scheduling.ParseClock(r.EndTime)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// SetOf converts ranges to a normalized scheduling.Set, skipping invalid and
+// empty ones.
+func SetOf(ranges []models.TimeRange) scheduling.Set {
+	intervals := make([]scheduling.Interval, 0, len(ranges))
+	for _, r := range ranges {
+		if i, err := scheduling.Parse(r.StartTime, r.EndTime); err == nil {
+			intervals = append(intervals, i)
+		}
+	}
+	return scheduling.Normalize(intervals)
+}
+
+// RangesOf converts intervals back to "HH:MM" ranges.
+func RangesOf(intervals []scheduling.Interval) models.TimeRanges {
+	ranges := make(models.TimeRanges, 0, len(intervals))
+	for _, i := range intervals {
+		ranges = append(ranges, models.TimeRange{StartTime: scheduling.FormatClock(i.Start), EndTime: scheduling.FormatClock(i.End)})
+	}
+	return ranges
+}
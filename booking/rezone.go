@@ -0,0 +1,219 @@
+package booking
+
+import (
+	"errors"
+	"time"
+
+	"taas-api/models"
+
+	"gorm.io/gorm"
+)
+
+// ConflictSpansMidnight is reported by Rezone for a booking that would cross
+// midnight in the new zone and so cannot be kept on one booking date.
+const ConflictSpansMidnight = "spans_midnight"
+
+// Rezone moves a talent's schedule into another time zone. Bookings, pending
+// reschedules and active holds from yesterday on keep their instants and are
+// rewritten on the new wall clock. Availability keeps its instants too, or its
+// wall-clock times if keepAvailabilityClock is set (e.g. to correct a wrongly
+// chosen zone). Weekly templates and date exceptions always keep their wall
+// clock. Open waitlist entries move to the matching day of the new zone. Nothing
+// is written when a booking or pending reschedule would cross midnight; those
+// are returned as conflicts. Call it inside a transaction.
+func Rezone(tx *gorm.DB, talentID string, to *time.Location, keepAvailabilityClock bool, now time.Time) ([]Conflict, error) {
+	if err := LockTalent(tx, talentID); err != nil {
+		return nil, err
+	}
+	from, err := TalentZone(tx, talentID)
+	if err != nil {
+		return nil, err
+	}
+	name := ZoneName(to)
+	if from.String() == to.String() {
+		return nil, tx.Model(&models.TalentRegistration{}).Where("talent_id = ?", talentID).Update("time_zone", name).Error
+	}
+	since := Day(now, from).AddDate(0, 0, -1)
+
+	// Convert everything first so that a conflict leaves the schedule untouched
+	var conflicts []Conflict
+	var bookings []models.BookingRequests
+	if err := tx.Where("talent_id = ? AND booking_date >= ? AND status NOT IN ?", talentID, since,
+		[]models.BookingStatus{models.Declined, models.Cancelled, models.Completed, models.NoShow}).Find(&bookings).Error; err != nil {
+		return nil, err
+	}
+	for i, b := range bookings {
+		date, ranges, conflict := convertDay(b.BookingDate, b.BookedTime, from, to)
+		if conflict != nil {
+			conflicts = append(conflicts, *conflict)
+			continue
+		}
+		bookings[i].BookingDate, bookings[i].BookedTime, bookings[i].TimeZone = date, ranges, name
+	}
+
+	var pending []models.RescheduleRequest
+	if err := tx.Where("status = ? AND booking_id IN (?)", models.ReschedulePending,
+		tx.Model(&models.BookingRequests{}).Select("booking_id").Where("talent_id = ?", talentID)).
+		Find(&pending).Error; err != nil {
+		return nil, err
+	}
+	for i, p := range pending {
+		date, ranges, conflict := convertDay(p.NewDate, p.NewTime, from, to)
+		if conflict != nil {
+			conflicts = append(conflicts, *conflict)
+			continue
+		}
+		pending[i].NewDate, pending[i].NewTime = date, ranges
+	}
+	if len(conflicts) > 0 {
+		return conflicts, nil
+	}
+
+	for _, b := range bookings {
+		if err := tx.Model(&models.BookingRequests{}).Where("booking_id = ?", b.BookingID).Updates(map[string]interface{}{
+			"booking_date": b.BookingDate, "booked_time": b.BookedTime, "time_zone": b.TimeZone,
+		}).Error; err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range pending {
+		if err := tx.Model(&p).Updates(map[string]interface{}{"new_date": p.NewDate, "new_time": p.NewTime}).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// Holds are short-lived; one that cannot be converted is released
+	var holds []models.BookingHold
+	if err := ActiveHolds(tx, now).Where("talent_id = ?", talentID).Find(&holds).Error; err != nil {
+		return nil, err
+	}
+	holdDates := make(map[string]time.Time)
+	for _, h := range holds {
+		date, ranges, conflict := convertDay(h.BookingDate, h.HeldTime, from, to)
+		updates := map[string]interface{}{"booking_date": date, "held_time": ranges}
+		if conflict != nil {
+			updates = map[string]interface{}{"released_at": now}
+		} else {
+			holdDates[h.HoldID] = date
+		}
+		if err := tx.Model(&h).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rezoneWaitlist(tx, talentID, since, from, to, holdDates); err != nil {
+		return nil, err
+	}
+
+	if err := rezoneAvailability(tx, talentID, since, from, to, keepAvailabilityClock); err != nil {
+		return nil, err
+	}
+	return nil, tx.Model(&models.TalentRegistration{}).Where("talent_id = ?", talentID).Update("time_zone", name).Error
+}
+
+// convertDay converts the ranges of one booking day and reports a conflict if
+// they do not all land on a single day of to.
+func convertDay(date time.Time, ranges models.TimeRanges, from, to *time.Location) (time.Time, models.TimeRanges, *Conflict) {
+	var day time.Time
+	var converted models.TimeRanges
+	for _, r := range ranges {
+		slot, err := Convert(date, r, from, to)
+		if err != nil || (!day.IsZero() && !slot.Date.Equal(day)) {
+			return time.Time{}, nil, &Conflict{Date: date.Format("2006-01-02"), StartTime: r.StartTime, EndTime: r.EndTime, Reason: ConflictSpansMidnight}
+		}
+		day = slot.Date
+		converted = append(converted, slot.Range)
+	}
+	return day, converted, nil
+}
+
+// rezoneWaitlist moves the open waitlist entries from since on to the new
+// calendar. An entry with an offer follows its hold; one still waiting asks for a
+// whole day and moves to the day of to that holds most of it.
+func rezoneWaitlist(tx *gorm.DB, talentID string, since time.Time, from, to *time.Location, holdDates map[string]time.Time) error {
+	var entries []models.WaitlistEntry
+	if err := tx.Where("talent_id = ? AND wait_date >= ? AND status IN ?", talentID, since,
+		[]string{models.WaitlistWaiting, models.WaitlistOffered}).Find(&entries).Error; err != nil {
+		return err
+	}
+	for _, e := range entries {
+		date, ok := holdDates[e.OfferHoldID]
+		if !ok {
+			date = wholeDayIn(e.WaitDate, from, to)
+		}
+		if date.Equal(e.WaitDate.UTC()) {
+			continue
+		}
+		if err := tx.Model(&e).Update("wait_date", date).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wholeDayIn returns the day of to that holds most of a calendar day of from:
+// the one its midday falls on.
+func wholeDayIn(date time.Time, from, to *time.Location) time.Time {
+	return Day(At(date, 12*60, from), to)
+}
+
+// rezoneAvailability rewrites the availability rows from since on. Converted
+// ranges may move to a neighbouring day and are merged into that day's row.
+func rezoneAvailability(tx *gorm.DB, talentID string, since time.Time, from, to *time.Location, keepClock bool) error {
+	name := ZoneName(to)
+	if keepClock {
+		return tx.Model(&models.AvailableTimeSlots{}).Where("talent_id = ? AND available_date >= ?", talentID, since).
+			Update("time_zone", name).Error
+	}
+
+	var rows []models.AvailableTimeSlots
+	if err := tx.Where("talent_id = ? AND available_date >= ?", talentID, since).Find(&rows).Error; err != nil {
+		return err
+	}
+	byDay := make(map[string][]models.TimeRange)
+	var days []time.Time
+	for _, row := range rows {
+		for _, r := range row.AvailableSlots {
+			pieces, err := ConvertSplit(row.AvailableDate, r, from, to)
+			if err != nil {
+				return err
+			}
+			for _, piece := range pieces {
+				key := piece.Date.Format("2006-01-02")
+				if _, ok := byDay[key]; !ok {
+					days = append(days, piece.Date)
+				}
+				byDay[key] = append(byDay[key], piece.Range)
+			}
+		}
+		if err := tx.Delete(&row).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, day := range days {
+		ranges := byDay[day.Format("2006-01-02")]
+		var existing models.AvailableTimeSlots
+		err := tx.Where("talent_id = ? AND available_date = ?", talentID, day).First(&existing).Error
+		if err == nil {
+			existing.AvailableSlots = append(existing.AvailableSlots, ranges...)
+			existing.TimeZone = name
+			if err := tx.Save(&existing).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err := tx.Create(&models.AvailableTimeSlots{
+			TalentID:       talentID,
+			AvailableDate:  day,
+			AvailableSlots: ranges,
+			TimeZone:       name,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
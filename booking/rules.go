@@ -0,0 +1,133 @@
+package booking
+
+import (
+	"errors"
+	"time"
+
+	"taas-api/models"
+
+	"gorm.io/gorm"
+)
+
+// Conflict reasons for slots that break the talent's scheduling rules.
+const (
+	ConflictTooSoon = "too_soon" // Starts within the minimum notice
+	ConflictTooFar  = "too_far"  // Lies beyond the booking horizon
+)
+
+// Upper bounds for the rule values a talent can set.
+const (
+	MaxBufferMinutes    = 4 * 60
+	MaxMinNoticeMinutes = 30 * 24 * 60
+	MaxHorizonDays      = 2 * 365
+)
+
+// Rules are the scheduling rules of a talent, optionally overridden by a card.
+// All durations are in minutes; MaxDaysInAdvance 0 means no horizon.
+type Rules struct {
+	BufferBefore     int `json:"buffer_before_minutes"`
+	BufferAfter      int `json:"buffer_after_minutes"`
+	MinNotice        int `json:"min_notice_minutes"`
+	MaxDaysInAdvance int `json:"max_days_in_advance"`
+}
+
+// DefaultRules apply to talents that have not set their own: no buffers, no
+// minimum notice and no horizon, as before the rules existed.
+var DefaultRules = Rules{}
+
+// ErrInvalidRules is returned by Validate for out-of-range values.
+var ErrInvalidRules = errors.New("invalid scheduling rules")
+
+// Validate checks that every value is within its bounds.
+func (r Rules) Validate() error {
+	if r.BufferBefore < 0 || r.BufferBefore > MaxBufferMinutes ||
+		r.BufferAfter < 0 || r.BufferAfter > MaxBufferMinutes ||
+		r.MinNotice < 0 || r.MinNotice > MaxMinNoticeMinutes ||
+		r.MaxDaysInAdvance < 0 || r.MaxDaysInAdvance > MaxHorizonDays {
+		return ErrInvalidRules
+	}
+	return nil
+}
+
+// Gap is the free time kept between two back-to-back sessions under these rules.
+func (r Rules) Gap() int {
+	return r.BufferAfter + r.BufferBefore
+}
+
+// Check reports ConflictTooSoon or ConflictTooFar for a session starting start
+// minutes after midnight of date on the wall clock of loc, or "" if it may be
+// booked at now.
+func (r Rules) Check(date time.Time, start int, now time.Time, loc *time.Location) string {
+	if At(date, start, loc).Before(now.Add(time.Duration(r.MinNotice) * time.Minute)) {
+		return ConflictTooSoon
+	}
+	if r.BeyondHorizon(date, now, loc) {
+		return ConflictTooFar
+	}
+	return ""
+}
+
+// BeyondHorizon reports whether date lies more than MaxDaysInAdvance days after
+// the day of now in loc.
+func (r Rules) BeyondHorizon(date time.Time, now time.Time, loc *time.Location) bool {
+	if r.MaxDaysInAdvance == 0 {
+		return false
+	}
+	return date.UTC().After(Day(now, loc).AddDate(0, 0, r.MaxDaysInAdvance))
+}
+
+// WithCard applies the overrides set on a card.
+func (r Rules) WithCard(card models.ServiceCard) Rules {
+	if card.BufferBeforeMinutes != nil {
+		r.BufferBefore = *card.BufferBeforeMinutes
+	}
+	if card.BufferAfterMinutes != nil {
+		r.BufferAfter = *card.BufferAfterMinutes
+	}
+	if card.MinNoticeMinutes != nil {
+		r.MinNotice = *card.MinNoticeMinutes
+	}
+	if card.MaxDaysInAdvance != nil {
+		r.MaxDaysInAdvance = *card.MaxDaysInAdvance
+	}
+	return r
+}
+
+// TalentRules returns the rules a talent has set, or DefaultRules.
+func TalentRules(db *gorm.DB, talentID string) (Rules, error) {
+	var settings models.TalentSchedulingRules
+	if err := db.Where("talent_id = ?", talentID).First(&settings).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return DefaultRules, nil
+		}
+		return Rules{}, err
+	}
+	return rulesOf(settings), nil
+}
+
+// rulesOf returns the rules stored in a talent's settings.
+func rulesOf(settings models.TalentSchedulingRules) Rules {
+	return Rules{
+		BufferBefore:     settings.BufferBeforeMinutes,
+		BufferAfter:      settings.BufferAfterMinutes,
+		MinNotice:        settings.MinNoticeMinutes,
+		MaxDaysInAdvance: settings.MaxDaysInAdvance,
+	}
+}
+
+// CardRules returns the rules for sessions of a card: the talent's rules with the
+// card's overrides. An empty or unknown cardID yields the talent's rules.
+func CardRules(db *gorm.DB, talentID string, cardID string) (Rules, error) {
+	rules, err := TalentRules(db, talentID)
+	if err != nil || cardID == "" {
+		return rules, err
+	}
+	var card models.ServiceCard
+	if err := db.Unscoped().Where("card_id = ?", cardID).First(&card).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return rules, nil
+		}
+		return Rules{}, err
+	}
+	return rules.WithCard(card), nil
+}
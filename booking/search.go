@@ -0,0 +1,340 @@
+package booking
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"taas-api/models"
+
+	"gorm.io/gorm"
+)
+
+// MaxSearchDays bounds the window of a Search.
+const MaxSearchDays = 14
+
+// ErrInvalidSearch is returned for windows that are empty, too long, or a
+// duration that is not positive.
+var ErrInvalidSearch = errors.New("invalid search window")
+
+// SearchQuery asks which talents have a card of Duration minutes that can be
+// booked between From and To.
+type SearchQuery struct {
+	From     time.Time
+	To       time.Time
+	Duration int      // Minutes; only cards of exactly this duration match
+	Category string   // Talent category; optional
+	Skills   []string // Every skill must be one of the talent's skills, ignoring case; optional
+	MinPrice int      // Optional lower bound of the card price
+	MaxPrice int      // Optional upper bound; 0 means none
+	UserID   string   // Searching user, whose own holds do not block slots
+	Limit    int      // Maximum number of talents; 0 means all
+}
+
+// OpenSlot is a bookable session of a card.
+type OpenSlot struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	SeatsLeft int       `json:"seats_left"`
+}
+
+// CardMatch is a card with its open slots in the search window.
+type CardMatch struct {
+	CardID    string     `json:"card_id"`
+	CardTitle string     `json:"card_title"`
+	Price     int        `json:"price"`
+	Duration  int        `json:"duration"`
+	Capacity  int        `json:"capacity"`
+	Slots     []OpenSlot `json:"slots"`
+}
+
+// TalentMatch is a talent with at least one card that can be booked in the
+// search window.
+type TalentMatch struct {
+	TalentID        string      `json:"talent_id"`
+	TalentName      string      `json:"talent_name"`
+	Category        string      `json:"category"`
+	ProfileImageURL string      `json:"profile_image_url"`
+	TimeZone        string      `json:"time_zone"`
+	Cards           []CardMatch `json:"cards"`
+}
+
+// Search finds the talents and cards with at least one bookable slot in the
+// window, ordered by their earliest slot. The database narrows the search to the
+// days on which a talent has a stretch of available, unbooked time as long as
+// the duration; on those, slots are tiled and checked exactly as for a single
+// talent: inside the expanded availability, clear of bookings and other users'
+// holds including buffers, and within minimum notice and horizon.
+func Search(db *gorm.DB, q SearchQuery, now time.Time) ([]TalentMatch, error) {
+	if q.Duration <= 0 || !q.From.Before(q.To) || q.To.Sub(q.From) > MaxSearchDays*24*time.Hour {
+		return nil, ErrInvalidSearch
+	}
+
+	query := db.Model(&models.ServiceCard{}).Select("service_cards.*").
+		Joins("JOIN talent_registrations ON talent_registrations.talent_id = service_cards.talent_id AND talent_registrations.deleted_at IS NULL").
+		Where("service_cards.duration = ?", q.Duration)
+	if q.Category != "" {
+		query = query.Where("talent_registrations.category = ?", q.Category)
+	}
+	// Skills are a comma-separated list; each skill must be one of its entries
+	for _, skill := range q.Skills {
+		if skill = strings.TrimSpace(skill); skill != "" {
+			query = query.Where("? = ANY(SELECT lower(trim(entry)) FROM unnest(string_to_array(talent_registrations.skills, ',')) AS entry)", strings.ToLower(skill))
+		}
+	}
+	if q.MinPrice > 0 {
+		query = query.Where("service_cards.price >= ?", q.MinPrice)
+	}
+	if q.MaxPrice > 0 {
+		query = query.Where("service_cards.price <= ?", q.MaxPrice)
+	}
+	var cards []models.ServiceCard
+	if err := query.Find(&cards).Error; err != nil {
+		return nil, err
+	}
+	if len(cards) == 0 {
+		return []TalentMatch{}, nil
+	}
+	var talentIDs []string
+	cardsOf := make(map[string][]models.ServiceCard)
+	for _, card := range cards {
+		if _, ok := cardsOf[card.TalentID]; !ok {
+			talentIDs = append(talentIDs, card.TalentID)
+		}
+		cardsOf[card.TalentID] = append(cardsOf[card.TalentID], card)
+	}
+
+	// Calendar days of every zone the window touches, as midnight UTC. Only the
+	// days on which the database finds a long enough stretch of free time are
+	// tiled and checked below, and only talents with such days are loaded.
+	first := Day(q.From, time.UTC).AddDate(0, 0, -1)
+	last := Day(q.To, time.UTC).AddDate(0, 0, 1)
+	openOf, err := openDays(db, talentIDs, first, last, q.Duration)
+	if err != nil {
+		return nil, err
+	}
+	talentIDs = talentIDs[:0]
+	var dates []time.Time
+	seenDate := make(map[time.Time]bool)
+	for talentID, days := range openOf {
+		talentIDs = append(talentIDs, talentID)
+		for _, d := range days {
+			if !seenDate[d] {
+				seenDate[d] = true
+				dates = append(dates, d)
+			}
+		}
+	}
+	if len(talentIDs) == 0 {
+		return []TalentMatch{}, nil
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	var talents []models.TalentRegistration
+	if err := db.Where("talent_id IN ?", talentIDs).Find(&talents).Error; err != nil {
+		return nil, err
+	}
+	var settings []models.TalentSchedulingRules
+	if err := db.Where("talent_id IN ?", talentIDs).Find(&settings).Error; err != nil {
+		return nil, err
+	}
+	talentRules := make(map[string]Rules, len(talentIDs))
+	for _, id := range talentIDs {
+		talentRules[id] = DefaultRules
+	}
+	for _, s := range settings {
+		talentRules[s.TalentID] = rulesOf(s)
+	}
+
+	var rows []models.AvailableTimeSlots
+	if err := db.Where("talent_id IN ? AND available_date IN ?", talentIDs, dates).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	var templates []models.AvailabilityTemplate
+	if err := db.Where("talent_id IN ? AND effective_from <= ? AND (effective_until IS NULL OR effective_until >= ?)", talentIDs, last, first).
+		Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	var exceptions []models.AvailabilityException
+	if err := db.Where("talent_id IN ? AND date IN ?", talentIDs, dates).Find(&exceptions).Error; err != nil {
+		return nil, err
+	}
+	rowsOf := make(map[string][]models.AvailableTimeSlots)
+	for _, row := range rows {
+		rowsOf[row.TalentID] = append(rowsOf[row.TalentID], row)
+	}
+	templatesOf := make(map[string][]models.AvailabilityTemplate)
+	for _, t := range templates {
+		templatesOf[t.TalentID] = append(templatesOf[t.TalentID], t)
+	}
+	exceptionsOf := make(map[string][]models.AvailabilityException)
+	for _, e := range exceptions {
+		exceptionsOf[e.TalentID] = append(exceptionsOf[e.TalentID], e)
+	}
+
+	occupied, err := occupiedByTalent(db, talentRules, dates, q.UserID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []TalentMatch
+	earliest := make(map[string]time.Time)
+	for _, talent := range talents {
+		loc, err := LoadZone(talent.TimeZone)
+		if err != nil {
+			continue
+		}
+		days := expand(openOf[talent.TalentID], templatesOf[talent.TalentID], exceptionsOf[talent.TalentID], rowsOf[talent.TalentID])
+
+		match := TalentMatch{
+			TalentID:        talent.TalentID,
+			TalentName:      talent.TalentName,
+			Category:        talent.Category,
+			ProfileImageURL: talent.ProfileImageURL,
+			TimeZone:        ZoneName(loc),
+		}
+		for _, card := range cardsOf[talent.TalentID] {
+			rules := talentRules[talent.TalentID].WithCard(card)
+			capacity := card.Capacity
+			if capacity < 1 {
+				capacity = 1
+			}
+			var slots []OpenSlot
+			for _, day := range days {
+				key := day.Date.Format("2006-01-02")
+				for _, slot := range TileDay(day, card.Duration, card.CardID, q.UserID, capacity, rules, occupied[talent.TalentID][key], loc, now) {
+					if !slot.Bookable || slot.Start.Before(q.From) || slot.End.After(q.To) {
+						continue
+					}
+					slots = append(slots, OpenSlot{Start: slot.Start, End: slot.End, SeatsLeft: slot.SeatsLeft})
+				}
+			}
+			if len(slots) == 0 {
+				continue
+			}
+			sort.Slice(slots, func(i, j int) bool { return slots[i].Start.Before(slots[j].Start) })
+			if e, ok := earliest[talent.TalentID]; !ok || slots[0].Start.Before(e) {
+				earliest[talent.TalentID] = slots[0].Start
+			}
+			match.Cards = append(match.Cards, CardMatch{
+				CardID:    card.CardID,
+				CardTitle: card.CardTitle,
+				Price:     card.Price,
+				Duration:  card.Duration,
+				Capacity:  capacity,
+				Slots:     slots,
+			})
+		}
+		if len(match.Cards) > 0 {
+			matches = append(matches, match)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return earliest[matches[i].TalentID].Before(earliest[matches[j].TalentID])
+	})
+	if q.Limit > 0 && len(matches) > q.Limit {
+		matches = matches[:q.Limit]
+	}
+	if matches == nil {
+		matches = []TalentMatch{}
+	}
+	return matches, nil
+}
+
+// openDaysSQL finds the days from @first to @last on which talents have a stretch
+// of at least @duration minutes that is available and not booked, on the
+// talent's wall clock. Availability follows expand: a blackout removes the day,
+// an override replaces the templates and one-off rows add to either. Buffers and
+// holds are left to the exact check, and bookings of group cards are not
+// subtracted because their sessions may have seats left, so no bookable day is
+// missed. Multiranges need PostgreSQL 14.
+const openDaysSQL = `
+WITH days AS (
+	SELECT d::date AS day FROM generate_series(CAST(@firstDay AS date), CAST(@lastDay AS date), interval '1 day') AS d
+),
+exceptions AS (
+	SELECT talent_id, (date AT TIME ZONE 'UTC')::date AS day, kind, slots
+	FROM availability_exceptions
+	WHERE talent_id IN @talents AND date >= @first AND date <= @last
+),
+ranges AS (
+	SELECT t.talent_id, d.day, r.value AS r, true AS available
+	FROM availability_templates t
+	JOIN days d ON d.day >= (t.effective_from AT TIME ZONE 'UTC')::date
+		AND (t.effective_until IS NULL OR d.day <= (t.effective_until AT TIME ZONE 'UTC')::date)
+		AND t.weekdays @> to_jsonb(EXTRACT(DOW FROM d.day)::int)
+	CROSS JOIN LATERAL jsonb_array_elements(t.slots) AS r
+	WHERE t.talent_id IN @talents AND t.deleted_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM exceptions e WHERE e.talent_id = t.talent_id AND e.day = d.day)
+	UNION ALL
+	SELECT e.talent_id, e.day, r.value, true
+	FROM exceptions e
+	CROSS JOIN LATERAL jsonb_array_elements(COALESCE(e.slots, '[]')) AS r
+	WHERE e.kind = @override
+	UNION ALL
+	SELECT a.talent_id, (a.available_date AT TIME ZONE 'UTC')::date, r.value, true
+	FROM available_time_slots a
+	CROSS JOIN LATERAL jsonb_array_elements(COALESCE(a.available_slots, '[]')) AS r
+	WHERE a.talent_id IN @talents AND a.available_date >= @first AND a.available_date <= @last AND a.deleted_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM exceptions e
+			WHERE e.talent_id = a.talent_id AND e.day = (a.available_date AT TIME ZONE 'UTC')::date AND e.kind = @blackout)
+	UNION ALL
+	SELECT b.talent_id, (b.booking_date AT TIME ZONE 'UTC')::date, r.value, false
+	FROM booking_requests b
+	LEFT JOIN service_cards c ON c.card_id = b.card_id
+	CROSS JOIN LATERAL jsonb_array_elements(COALESCE(b.booked_time, '[]')) AS r
+	WHERE b.talent_id IN @talents AND b.booking_date >= @first AND b.booking_date <= @last
+		AND b.status NOT IN @inactive AND b.deleted_at IS NULL AND COALESCE(c.capacity, 1) <= 1
+),
+spans AS (
+	SELECT talent_id, day, available, int4range(m.start_minute, m.end_minute) AS span
+	FROM ranges
+	CROSS JOIN LATERAL (SELECT
+		CASE WHEN r->>'startTime' ~ '^[0-9]{1,2}:[0-9]{2}$' THEN (EXTRACT(EPOCH FROM (r->>'startTime')::interval) / 60)::int END AS start_minute,
+		CASE WHEN r->>'endTime' ~ '^[0-9]{1,2}:[0-9]{2}$' THEN (EXTRACT(EPOCH FROM (r->>'endTime')::interval) / 60)::int END AS end_minute
+	) AS m
+	WHERE m.start_minute < m.end_minute
+),
+free AS (
+	SELECT talent_id, day,
+		range_agg(span) FILTER (WHERE available) AS available,
+		COALESCE(range_agg(span) FILTER (WHERE NOT available), '{}') AS booked
+	FROM spans
+	GROUP BY talent_id, day
+)
+SELECT DISTINCT free.talent_id, free.day
+FROM free
+CROSS JOIN LATERAL unnest(free.available - free.booked) AS stretch
+WHERE upper(stretch) - lower(stretch) >= @duration
+ORDER BY free.talent_id, free.day
+`
+
+// openDays returns, per talent, the days (midnight UTC) from first to last on
+// which openDaysSQL finds a free stretch of duration minutes, in date order.
+func openDays(db *gorm.DB, talentIDs []string, first, last time.Time, duration int) (map[string][]time.Time, error) {
+	var found []struct {
+		TalentID string
+		Day      time.Time
+	}
+	if err := db.Raw(openDaysSQL, map[string]interface{}{
+		"talents":  talentIDs,
+		"first":    first,
+		"last":     last,
+		"firstDay": first.Format("2006-01-02"),
+		"lastDay":  last.Format("2006-01-02"),
+		"duration": duration,
+		"inactive": InactiveStatuses,
+		"override": models.ExceptionOverride,
+		"blackout": models.ExceptionBlackout,
+	}).Scan(&found).Error; err != nil {
+		return nil, err
+	}
+	open := make(map[string][]time.Time)
+	for _, f := range found {
+		day := time.Date(f.Day.Year(), f.Day.Month(), f.Day.Day(), 0, 0, 0, 0, time.UTC)
+		open[f.TalentID] = append(open[f.TalentID], day)
+	}
+	return open, nil
+}
@@ -0,0 +1,44 @@
+package booking
+
+import (
+	"fmt"
+	"time"
+
+	"taas-api/models"
+
+	"gorm.io/gorm"
+)
+
+// MaxSeriesOccurrences caps how many sessions one series request may create.
+const MaxSeriesOccurrences = 52
+
+// SeriesDates returns the dates of count occurrences starting at start.
+func SeriesDates(start time.Time, frequency string, count int) ([]time.Time, error) {
+	var step int
+	switch frequency {
+	case models.FrequencyWeekly:
+		step = 7
+	case models.FrequencyBiweekly:
+		step = 14
+	default:
+		return nil, fmt.Errorf("frequency must be %q or %q", models.FrequencyWeekly, models.FrequencyBiweekly)
+	}
+	if count < 1 || count > MaxSeriesOccurrences {
+		return nil, fmt.Errorf("occurrences must be between 1 and %d", MaxSeriesOccurrences)
+	}
+	dates := make([]time.Time, count)
+	for i := range dates {
+		dates[i] = start.AddDate(0, 0, i*step)
+	}
+	return dates, nil
+}
+
+// FutureOccurrences returns the occurrences of a series on or after from that
+// still occupy time, oldest first.
+func FutureOccurrences(tx *gorm.DB, seriesID string, from time.Time) ([]models.BookingRequests, error) {
+	var occurrences []models.BookingRequests
+	finished := []models.BookingStatus{models.Declined, models.Cancelled, models.Completed, models.NoShow}
+	err := tx.Where("series_id = ? AND booking_date >= ? AND status NOT IN ?", seriesID, from, finished).
+		Order("booking_date").Find(&occurrences).Error
+	return occurrences, err
+}
@@ -0,0 +1,178 @@
+package booking
+
+import (
+	"sync"
+	"time"
+
+	"taas-api/models"
+
+	"gorm.io/gorm"
+)
+
+// SnapshotTTL bounds how long a cached snapshot is served. Writes through this
+// server invalidate snapshots at once; the TTL catches writes it does not see,
+// such as those of another instance.
+const SnapshotTTL = time.Minute
+
+// Snapshot is a talent's schedule over a range of days: the expanded
+// availability and the booked sessions with their buffers. Holds are not part of
+// it because they expire on their own; load them with HeldOccupancy.
+type Snapshot struct {
+	From   time.Time
+	To     time.Time // Zero for an open range, as in Availability
+	Days   []DayAvailability
+	Booked map[string][]Occupancy // Active bookings per date ("2006-01-02")
+
+	loadedAt time.Time
+}
+
+// snapshotWindow identifies the days a snapshot covers by their Unix seconds;
+// the zero time of an open range has its own.
+type snapshotWindow struct {
+	from, to int64
+}
+
+func windowOf(from, to time.Time) snapshotWindow {
+	return snapshotWindow{from: from.Unix(), to: to.Unix()}
+}
+
+// talentSnapshots are the cached snapshots of one talent, one per window. The
+// generation lets a load that raced with an invalidation be dropped instead of
+// cached; loading counts the loads in flight, which keep the entry alive so
+// that they compare against the right generation.
+type talentSnapshots struct {
+	windows    map[snapshotWindow]*Snapshot
+	generation uint64
+	loading    int
+}
+
+// snapshotCache holds the snapshots per talent and window. Expired snapshots
+// are swept at most once per SnapshotTTL, and talents without snapshots or loads
+// in flight are dropped, so the cache only grows with the talents being viewed.
+type snapshotCache struct {
+	mu         sync.Mutex
+	talents    map[string]*talentSnapshots
+	lastPruned time.Time
+}
+
+var snapshots = snapshotCache{talents: make(map[string]*talentSnapshots)}
+
+// LoadSnapshot returns the talent's snapshot for the days from from to to, from
+// the cache when one for the same days is fresh. Callers must not modify it.
+func LoadSnapshot(db *gorm.DB, talentID string, from, to time.Time, now time.Time) (*Snapshot, error) {
+	window := windowOf(from, to)
+	snapshots.mu.Lock()
+	entry, ok := snapshots.talents[talentID]
+	if !ok {
+		entry = &talentSnapshots{windows: make(map[snapshotWindow]*Snapshot)}
+		snapshots.talents[talentID] = entry
+	}
+	if cached, ok := entry.windows[window]; ok && now.Sub(cached.loadedAt) < SnapshotTTL {
+		snapshots.mu.Unlock()
+		return cached, nil
+	}
+	generation := entry.generation
+	entry.loading++
+	snapshots.mu.Unlock()
+
+	snapshot, err := loadSnapshot(db, talentID, from, to)
+	if err == nil {
+		snapshot.loadedAt = now
+	}
+
+	snapshots.mu.Lock()
+	entry.loading--
+	if err == nil && entry.generation == generation {
+		entry.windows[window] = snapshot
+	}
+	snapshots.prune(talentID, entry, now)
+	snapshots.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// InvalidateSnapshot drops the cached snapshots of a talent. Call it after a
+// write to the talent's bookings, availability or scheduling rules has
+// committed.
+func InvalidateSnapshot(talentID string) {
+	snapshots.mu.Lock()
+	if entry, ok := snapshots.talents[talentID]; ok {
+		entry.windows = make(map[snapshotWindow]*Snapshot)
+		entry.generation++
+		if entry.loading == 0 {
+			delete(snapshots.talents, talentID)
+		}
+	}
+	snapshots.mu.Unlock()
+}
+
+// prune drops the expired snapshots of the talent, and once per SnapshotTTL
+// those of every talent, along with talents left without snapshots or loads.
+// The caller holds c.mu.
+func (c *snapshotCache) prune(talentID string, entry *talentSnapshots, now time.Time) {
+	expire := func(talentID string, entry *talentSnapshots) {
+		for window, snapshot := range entry.windows {
+			if now.Sub(snapshot.loadedAt) >= SnapshotTTL {
+				delete(entry.windows, window)
+			}
+		}
+		if len(entry.windows) == 0 && entry.loading == 0 {
+			delete(c.talents, talentID)
+		}
+	}
+	expire(talentID, entry)
+	if now.Sub(c.lastPruned) < SnapshotTTL {
+		return
+	}
+	c.lastPruned = now
+	for id, e := range c.talents {
+		expire(id, e)
+	}
+}
+
+// loadSnapshot reads a snapshot with one query per table: bookings of all the
+// days are fetched in a single range query and grouped by date.
+func loadSnapshot(db *gorm.DB, talentID string, from, to time.Time) (*Snapshot, error) {
+	days, err := Availability(db, talentID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{From: from, To: to, Days: days, Booked: make(map[string][]Occupancy)}
+	if len(days) == 0 {
+		return snapshot, nil
+	}
+
+	var bookings []models.BookingRequests
+	if err := db.Where("talent_id = ? AND booking_date >= ? AND booking_date <= ? AND status NOT IN ?",
+		talentID, days[0].Date, days[len(days)-1].Date, InactiveStatuses).Find(&bookings).Error; err != nil {
+		return nil, err
+	}
+	for _, b := range bookings {
+		key := b.BookingDate.UTC().Format("2006-01-02")
+		for _, r := range b.BookedTime {
+			snapshot.Booked[key] = append(snapshot.Booked[key], Occupancy{Range: r, CardID: b.CardID, UserID: b.UserID})
+		}
+	}
+	return snapshot, withTalentBuffers(db, talentID, snapshot.Booked)
+}
+
+// HeldOccupancy returns the active holds of users other than exceptHoldsOf on
+// the talent's days from from to to, per date and with their buffers.
+func HeldOccupancy(db *gorm.DB, talentID string, from, to time.Time, exceptHoldsOf string, now time.Time) (map[string][]Occupancy, error) {
+	var holds []models.BookingHold
+	if err := ActiveHolds(db, now).
+		Where("talent_id = ? AND booking_date >= ? AND booking_date <= ? AND user_id <> ?", talentID, from, to, exceptHoldsOf).
+		Find(&holds).Error; err != nil {
+		return nil, err
+	}
+	held := make(map[string][]Occupancy)
+	for _, h := range holds {
+		key := h.BookingDate.UTC().Format("2006-01-02")
+		for _, r := range h.HeldTime {
+			held[key] = append(held[key], Occupancy{Range: r, CardID: h.CardID, UserID: h.UserID})
+		}
+	}
+	return held, withTalentBuffers(db, talentID, held)
+}
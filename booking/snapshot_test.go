@@ -0,0 +1,140 @@
+package booking
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"taas-api/models"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// yearOfSchedule is a busy talent's year from from: weekday templates in the
+// morning and afternoon, a blackout every four weeks, a shortened day every
+// week and four one-hour bookings on every open day.
+func yearOfSchedule(talentID string, from time.Time) ([]models.AvailabilityTemplate, []models.AvailabilityException, []models.BookingRequests) {
+	templates := []models.AvailabilityTemplate{
+		{TalentID: talentID, Weekdays: models.Weekdays{1, 2, 3, 4, 5}, Slots: models.TimeRanges{{StartTime: "09:00", EndTime: "12:00"}}, EffectiveFrom: from},
+		{TalentID: talentID, Weekdays: models.Weekdays{1, 2, 3, 4, 5}, Slots: models.TimeRanges{{StartTime: "13:00", EndTime: "18:00"}}, EffectiveFrom: from},
+		{TalentID: talentID, Weekdays: models.Weekdays{6}, Slots: models.TimeRanges{{StartTime: "10:00", EndTime: "14:00"}}, EffectiveFrom: from},
+	}
+	var exceptions []models.AvailabilityException
+	var bookings []models.BookingRequests
+	for day := 0; day < 365; day++ {
+		date := from.AddDate(0, 0, day)
+		switch {
+		case day%28 == 27:
+			exceptions = append(exceptions, models.AvailabilityException{TalentID: talentID, Date: date, Kind: models.ExceptionBlackout})
+			continue
+		case day%7 == 3:
+			exceptions = append(exceptions, models.AvailabilityException{TalentID: talentID, Date: date, Kind: models.ExceptionOverride,
+				Slots: models.TimeRanges{{StartTime: "09:00", EndTime: "15:00"}}})
+		}
+		sessions := models.TimeRanges{{StartTime: "09:00", EndTime: "10:00"}, {StartTime: "10:30", EndTime: "11:30"},
+			{StartTime: "13:00", EndTime: "14:00"}, {StartTime: "14:30", EndTime: "15:30"}}
+		for i, session := range sessions {
+			bookings = append(bookings, models.BookingRequests{
+				BookingID:   fmt.Sprintf("%s-%d-%d", talentID, day, i),
+				CardID:      talentID + "-card",
+				UserID:      fmt.Sprintf("user-%d", i),
+				TalentID:    talentID,
+				BookedTime:  models.TimeRanges{session},
+				BookingDate: date,
+				Status:      models.Accepted,
+			})
+		}
+	}
+	return templates, exceptions, bookings
+}
+
+// BenchmarkFilteredSchedule runs the filtered schedule of a card over a year:
+// expanding templates and exceptions and tiling every day around the bookings.
+func BenchmarkFilteredSchedule(b *testing.B) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	templates, exceptions, bookings := yearOfSchedule("bench", from)
+	var dates []time.Time
+	for d := 0; d < 365; d++ {
+		dates = append(dates, from.AddDate(0, 0, d))
+	}
+	booked := make(map[string][]Occupancy)
+	for _, bk := range bookings {
+		key := bk.BookingDate.Format("2006-01-02")
+		booked[key] = append(booked[key], Occupancy{Range: bk.BookedTime[0], CardID: bk.CardID, UserID: bk.UserID, BufferAfter: 10})
+	}
+	rules := Rules{BufferAfter: 10, MinNotice: 60}
+	now := from.Add(-time.Hour)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		slots := 0
+		for _, day := range expand(dates, templates, exceptions, nil) {
+			for _, slot := range TileDay(day, 30, "bench-card", "user-x", 1, rules, booked[day.Date.Format("2006-01-02")], time.UTC, now) {
+				if slot.Bookable {
+					slots++
+				}
+			}
+		}
+		if slots == 0 {
+			b.Fatal("no bookable slots in a year")
+		}
+	}
+}
+
+// BenchmarkLoadSnapshot loads a year of a talent's schedule from the database
+// named by TEST_DATABASE_DSN, a Postgres DSN; it is skipped without one. The
+// data is written in a transaction that is rolled back afterwards.
+func BenchmarkLoadSnapshot(b *testing.B) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		b.Skip("TEST_DATABASE_DSN is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.ServiceCard{}, &models.TalentSchedulingRules{}, &models.AvailableTimeSlots{},
+		&models.AvailabilityTemplate{}, &models.AvailabilityException{}, &models.BookingRequests{}, &models.BookingHold{}); err != nil {
+		b.Fatal(err)
+	}
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	talentID := fmt.Sprintf("bench-%d", time.Now().UnixNano())
+	from := Day(time.Now(), time.UTC)
+	to := from.AddDate(0, 0, 364)
+	templates, exceptions, bookings := yearOfSchedule(talentID, from)
+	for _, rows := range []interface{}{&templates, &exceptions, &bookings} {
+		if err := tx.CreateInBatches(rows, 500).Error; err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			InvalidateSnapshot(talentID)
+			snapshot, err := LoadSnapshot(tx, talentID, from, to, time.Now())
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := HeldOccupancy(tx, talentID, from, to, "", time.Now()); err != nil {
+				b.Fatal(err)
+			}
+			if len(snapshot.Days) == 0 {
+				b.Fatal("empty snapshot")
+			}
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			if _, err := LoadSnapshot(tx, talentID, from, to, time.Now()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	InvalidateSnapshot(talentID)
+}
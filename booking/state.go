@@ -0,0 +1,157 @@
+// Package booking holds the domain rules for BookingRequests that are shared by
+// several handlers.
+package booking
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"taas-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Actor roles that may change the status of a booking.
+const (
+	ActorUser   = "user"   // The account that made the booking
+	ActorTalent = "talent" // The owner of the booked talent
+	ActorAdmin  = "admin"
+	ActorSystem = "system" // Background jobs
+)
+
+var (
+	// ErrNotFound is returned when the booking does not exist.
+	ErrNotFound = errors.New("booking not found")
+	// ErrIllegalTransition is returned for a move the state machine does not allow.
+	ErrIllegalTransition = errors.New("illegal booking status transition")
+)
+
+// transitions lists, per current status, the statuses it may move to and the
+// actor roles allowed to make that move. Terminal statuses have no entry.
+var transitions = map[models.BookingStatus]map[models.BookingStatus][]string{
+	models.Requested: {
+		models.Accepted:  {ActorTalent, ActorAdmin},
+		models.Declined:  {ActorTalent, ActorAdmin},
+		models.Cancelled: {ActorUser, ActorAdmin, ActorSystem},
+	},
+	models.Accepted: {
+		models.Scheduled: {ActorTalent, ActorAdmin, ActorSystem},
+		models.Cancelled: {ActorUser, ActorTalent, ActorAdmin},
+	},
+	models.Scheduled: {
+		models.InProgress: {ActorTalent, ActorAdmin, ActorSystem},
+		models.NoShow:     {ActorTalent, ActorAdmin},
+		models.Cancelled:  {ActorUser, ActorTalent, ActorAdmin},
+	},
+	models.InProgress: {
+		models.Completed: {ActorTalent, ActorAdmin, ActorSystem},
+		models.NoShow:    {ActorTalent, ActorAdmin},
+		models.Cancelled: {ActorTalent, ActorAdmin},
+	},
+}
+
+// CanTransition reports whether role may move a booking from one status to another.
+func CanTransition(from, to models.BookingStatus, role string) bool {
+	for _, allowed := range transitions[from][to] {
+		if allowed == role {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedTransitions returns the statuses role may move a booking in status from to.
+func AllowedTransitions(from models.BookingStatus, role string) []models.BookingStatus {
+	var next []models.BookingStatus
+	for _, to := range statusOrder {
+		if CanTransition(from, to, role) {
+			next = append(next, to)
+		}
+	}
+	return next
+}
+
+// statusOrder keeps AllowedTransitions output stable.
+var statusOrder = []models.BookingStatus{
+	models.Requested, models.Accepted, models.Declined, models.Scheduled,
+	models.InProgress, models.Completed, models.NoShow, models.Cancelled,
+}
+
+// IsTerminal reports whether no further transitions are possible from status.
+func IsTerminal(status models.BookingStatus) bool {
+	return len(transitions[status]) == 0
+}
+
+// Actor identifies who changes a booking.
+type Actor struct {
+	ID   string // User ID; empty for ActorSystem
+	Role string
+}
+
+// TransitionError describes a rejected transition.
+type TransitionError struct {
+	From, To models.BookingStatus
+	Role     string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s may not move a booking from %s to %s", e.Role, e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() error { return ErrIllegalTransition }
+
+// Transition locks the booking, checks the move against the state machine,
+// updates its status and records the change. It must run inside a transaction.
+func Transition(tx *gorm.DB, bookingID string, to models.BookingStatus, actor Actor, reason string) (models.BookingRequests, error) {
+	var b models.BookingRequests
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("booking_id = ?", bookingID).First(&b).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return b, ErrNotFound
+		}
+		return b, err
+	}
+	if !CanTransition(b.Status, to, actor.Role) {
+		return b, &TransitionError{From: b.Status, To: to, Role: actor.Role}
+	}
+
+	from := b.Status
+	b.Status = to
+	b.UpdatedAt = time.Now()
+	if err := tx.Model(&b).Updates(map[string]interface{}{"status": to, "updated_at": b.UpdatedAt}).Error; err != nil {
+		return b, err
+	}
+	return b, RecordTransition(tx, b.BookingID, from, to, actor, reason)
+}
+
+// Create inserts a new booking in status Requested and records that initial
+// status. Bookings without a time zone are kept in the talent's.
+func Create(tx *gorm.DB, b *models.BookingRequests, actor Actor) error {
+	b.Status = models.Requested
+	if b.TimeZone == "" {
+		loc, err := TalentZone(tx, b.TalentID)
+		if err != nil {
+			return err
+		}
+		b.TimeZone = ZoneName(loc)
+	}
+	if err := tx.Create(b).Error; err != nil {
+		return err
+	}
+	return RecordTransition(tx, b.BookingID, "", models.Requested, actor, "")
+}
+
+// RecordTransition writes a history row. Use it directly only for the initial
+// status of a new booking; all later changes go through Transition.
+func RecordTransition(tx *gorm.DB, bookingID string, from, to models.BookingStatus, actor Actor, reason string) error {
+	return tx.Create(&models.BookingStatusHistory{
+		BookingID:  bookingID,
+		FromStatus: from,
+		ToStatus:   to,
+		ActorID:    actor.ID,
+		ActorRole:  actor.Role,
+		Reason:     reason,
+	}).Error
+}
@@ -0,0 +1,151 @@
+package booking
+
+import (
+	"errors"
+	"time"
+
+	"taas-api/models"
+	"taas-api/scheduling"
+
+	"gorm.io/gorm"
+	"storj.io/common/uuid"
+)
+
+// WaitlistOfferDuration is how long a waitlisted user has to confirm an offered slot.
+const WaitlistOfferDuration = 30 * time.Minute
+
+// Offer is a slot put on hold for a waitlisted user.
+type Offer struct {
+	Entry models.WaitlistEntry
+	Hold  models.BookingHold
+}
+
+// OfferFreedTime offers slots inside the given ranges of a talent's date to the
+// users waiting for it, in the order they joined. Each user gets at most one slot
+// of their card's duration that is available, unbooked and not held by anyone
+// else; offering stops when no such slot is left. Call it after LockTalent and
+// notify the returned offers once the transaction has committed.
+func OfferFreedTime(tx *gorm.DB, talentID string, date time.Time, ranges models.TimeRanges, now time.Time) ([]Offer, error) {
+	var entries []models.WaitlistEntry
+	if err := tx.Where("talent_id = ? AND wait_date = ? AND status = ?", talentID, date, models.WaitlistWaiting).
+		Order("created_at, id").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	var offers []Offer
+	for _, entry := range entries {
+		var card models.ServiceCard
+		if err := tx.Where("card_id = ?", entry.CardID).First(&card).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue // The card was deleted; nothing can be offered for it
+			}
+			return nil, err
+		}
+		slot, found, err := firstFreeSlot(tx, talentID, card.CardID, entry.UserID, date, ranges, time.Duration(card.Duration)*time.Minute, now)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			// Later users wait for other cards, which may have shorter sessions
+			continue
+		}
+
+		holdID, err := uuid.New()
+		if err != nil {
+			return nil, err
+		}
+		expiresAt := now.Add(WaitlistOfferDuration)
+		hold := models.BookingHold{
+			HoldID:      holdID.String(),
+			Kind:        models.HoldWaitlist,
+			UserID:      entry.UserID,
+			TalentID:    talentID,
+			CardID:      card.CardID,
+			CardTitle:   card.CardTitle,
+			SessionType: entry.SessionType,
+			BookingDate: date,
+			HeldTime:    models.TimeRanges{slot},
+			ExpiresAt:   expiresAt,
+		}
+		if err := tx.Create(&hold).Error; err != nil {
+			return nil, err
+		}
+		entry.Status = models.WaitlistOffered
+		entry.OfferHoldID = hold.HoldID
+		entry.OfferExpiresAt = &expiresAt
+		if err := tx.Model(&entry).Updates(map[string]interface{}{
+			"status": entry.Status, "offer_hold_id": entry.OfferHoldID, "offer_expires_at": expiresAt,
+		}).Error; err != nil {
+			return nil, err
+		}
+		offers = append(offers, Offer{Entry: entry, Hold: hold})
+	}
+	return offers, nil
+}
+
+// firstFreeSlot tiles the ranges with slots of the given duration and returns the
+// first one of the card that userID could book.
+func firstFreeSlot(tx *gorm.DB, talentID, cardID, userID string, date time.Time, ranges models.TimeRanges, duration time.Duration, now time.Time) (models.TimeRange, bool, error) {
+	if duration <= 0 {
+		return models.TimeRange{}, false, nil
+	}
+	loc, err := TalentZone(tx, talentID)
+	if err != nil {
+		return models.TimeRange{}, false, err
+	}
+	for _, slot := range RangesOf(scheduling.Tile(SetOf(ranges), scheduling.TileOptions{Duration: int(duration.Minutes())})) {
+		start, _, _ := ClockRange(slot)
+		if !At(date, start, loc).After(now) {
+			continue
+		}
+		conflicts, err := CheckSlots(tx, talentID, cardID, userID, []Slot{{Date: date, Range: slot}})
+		if err != nil {
+			return models.TimeRange{}, false, err
+		}
+		if len(conflicts) == 0 {
+			return slot, true, nil
+		}
+	}
+	return models.TimeRange{}, false, nil
+}
+
+// ExpireWaitlistOffers closes offers whose hold ran out without a booking and
+// passes the time on to the next users in line. Offers of confirmed holds are
+// marked as booked.
+func ExpireWaitlistOffers(db *gorm.DB, now time.Time) ([]Offer, error) {
+	var expired []models.WaitlistEntry
+	if err := db.Where("status = ? AND offer_expires_at <= ?", models.WaitlistOffered, now).
+		Find(&expired).Error; err != nil {
+		return nil, err
+	}
+
+	var offers []Offer
+	for _, entry := range expired {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := LockTalent(tx, entry.TalentID); err != nil {
+				return err
+			}
+			var hold models.BookingHold
+			if err := tx.Where("hold_id = ?", entry.OfferHoldID).First(&hold).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if hold.BookingID != "" {
+				return tx.Model(&entry).Update("status", models.WaitlistBooked).Error
+			}
+			if err := tx.Model(&entry).Update("status", models.WaitlistExpired).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.BookingHold{}).Where("hold_id = ? AND released_at IS NULL", entry.OfferHoldID).
+				Update("released_at", now).Error; err != nil {
+				return err
+			}
+			next, err := OfferFreedTime(tx, entry.TalentID, entry.WaitDate, hold.HeldTime, now)
+			offers = append(offers, next...)
+			return err
+		})
+		if err != nil {
+			return offers, err
+		}
+	}
+	return offers, nil
+}
@@ -0,0 +1,153 @@
+package booking
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"taas-api/models"
+
+	"gorm.io/gorm"
+)
+
+// Booking and availability dates are calendar days stored as midnight UTC, and
+// their "HH:MM" ranges are wall-clock times in the zone recorded on the row,
+// which is the talent's zone. The helpers below turn them into instants and
+// move them between zones.
+
+var (
+	// ErrInvalidTimeZone is returned for names that are not IANA time zones.
+	ErrInvalidTimeZone = errors.New("invalid time zone")
+	// ErrSpansMidnight is returned when a range would cross midnight after
+	// conversion and so cannot be stored on one day.
+	ErrSpansMidnight = errors.New("time range spans midnight")
+)
+
+// LoadZone resolves an IANA time zone name. The empty name is the server's
+// local zone, which rows written before zones were recorded are meant in.
+func LoadZone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTimeZone, name)
+	}
+	return loc, nil
+}
+
+// ZoneName returns the name to store for loc; the server's local zone is "".
+func ZoneName(loc *time.Location) string {
+	if loc == time.Local {
+		return ""
+	}
+	return loc.String()
+}
+
+// TalentZone returns the time zone a talent's schedule is kept in.
+func TalentZone(db *gorm.DB, talentID string) (*time.Location, error) {
+	var registration models.TalentRegistration
+	if err := db.Select("time_zone").Where("talent_id = ?", talentID).First(&registration).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTalentNotFound
+		}
+		return nil, err
+	}
+	return LoadZone(registration.TimeZone)
+}
+
+// Day returns the calendar day of t in loc, as midnight UTC.
+func Day(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// At returns the instant minute minutes after midnight of date on the wall clock
+// of loc. A wall-clock time skipped by a daylight saving change is moved forward
+// by the length of the gap (02:30 becomes 03:30); a repeated one resolves to its
+// first occurrence.
+func At(date time.Time, minute int, loc *time.Location) time.Time {
+	d := date.UTC()
+	t := time.Date(d.Year(), d.Month(), d.Day(), 0, minute, 0, 0, loc)
+	wall := time.Date(d.Year(), d.Month(), d.Day(), 0, minute, 0, 0, time.UTC)
+	if got := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC); !got.Equal(wall) {
+		_, before := t.Zone()
+		_, after := t.Add(6 * time.Hour).Zone()
+		t = t.Add(time.Duration(after-before) * time.Second)
+	}
+	return t
+}
+
+// Interval returns the instants a range of a calendar day covers in loc.
+func Interval(date time.Time, r models.TimeRange, loc *time.Location) (time.Time, time.Time, error) {
+	start, end, err := ClockRange(r)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return At(date, start, loc), At(date, end, loc), nil
+}
+
+// Convert moves a range of a calendar day from the wall clock of one zone to
+// another. It fails with ErrSpansMidnight if the range crosses midnight in to.
+func Convert(date time.Time, r models.TimeRange, from, to *time.Location) (Slot, error) {
+	if from.String() == to.String() {
+		return Slot{Date: date, Range: r}, nil
+	}
+	pieces, err := ConvertSplit(date, r, from, to)
+	if err != nil {
+		return Slot{}, err
+	}
+	if len(pieces) != 1 {
+		return Slot{}, ErrSpansMidnight
+	}
+	return pieces[0], nil
+}
+
+// ConvertSplit is Convert for availability: a range that crosses midnight in to
+// is split into one piece per day, the first ending at "24:00".
+func ConvertSplit(date time.Time, r models.TimeRange, from, to *time.Location) ([]Slot, error) {
+	if from.String() == to.String() {
+		return []Slot{{Date: date, Range: r}}, nil
+	}
+	start, end, err := Interval(date, r, from)
+	if err != nil {
+		return nil, err
+	}
+	var pieces []Slot
+	for start.Before(end) {
+		day := Day(start, to)
+		midnight := At(day.AddDate(0, 0, 1), 0, to)
+		pieceEnd := end
+		if midnight.Before(end) {
+			pieceEnd = midnight
+		}
+		pieces = append(pieces, Slot{Date: day, Range: models.TimeRange{
+			StartTime: wallClock(start, day, to),
+			EndTime:   wallClock(pieceEnd, day, to),
+		}})
+		start = pieceEnd
+	}
+	return pieces, nil
+}
+
+// Render shows a range of a calendar day in from on the wall clock of to and
+// returns the day it starts on there. Unlike Convert it never fails on ranges
+// that cross midnight; their end is the time on the next day.
+func Render(date time.Time, r models.TimeRange, from, to *time.Location) (time.Time, models.TimeRange, error) {
+	if from.String() == to.String() {
+		return date, r, nil
+	}
+	start, end, err := Interval(date, r, from)
+	if err != nil {
+		return time.Time{}, models.TimeRange{}, err
+	}
+	return Day(start, to), models.TimeRange{StartTime: start.In(to).Format("15:04"), EndTime: end.In(to).Format("15:04")}, nil
+}
+
+// wallClock formats t as "HH:MM" on day in loc; midnight at the end of the day is "24:00".
+func wallClock(t time.Time, day time.Time, loc *time.Location) string {
+	if Day(t, loc).After(day) {
+		return "24:00"
+	}
+	return t.In(loc).Format("15:04")
+}
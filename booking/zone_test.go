@@ -0,0 +1,204 @@
+package booking
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"taas-api/models"
+	"taas-api/scheduling"
+)
+
+func mustZone(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := LoadZone(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func date(s string) time.Time {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestAt(t *testing.T) {
+	newYork := mustZone(t, "America/New_York")
+	tests := []struct {
+		name   string
+		date   string
+		minute int
+		want   time.Time
+	}{
+		{"summer", "2026-06-01", 9 * 60, time.Date(2026, 6, 1, 13, 0, 0, 0, time.UTC)},
+		{"winter", "2026-01-15", 9 * 60, time.Date(2026, 1, 15, 14, 0, 0, 0, time.UTC)},
+		{"end of day", "2026-06-01", scheduling.DayMinutes, time.Date(2026, 6, 2, 4, 0, 0, 0, time.UTC)},
+		// 02:30 does not exist on the spring-forward day and moves to 03:30 EDT
+		{"spring forward gap", "2026-03-08", 2*60 + 30, time.Date(2026, 3, 8, 7, 30, 0, 0, time.UTC)},
+		{"spring forward after", "2026-03-08", 3 * 60, time.Date(2026, 3, 8, 7, 0, 0, 0, time.UTC)},
+		{"spring forward before", "2026-03-08", 1*60 + 59, time.Date(2026, 3, 8, 6, 59, 0, 0, time.UTC)},
+		// 01:30 happens twice on the fall-back day; the first is in EDT
+		{"fall back overlap", "2026-11-01", 1*60 + 30, time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC)},
+		{"fall back after", "2026-11-01", 2 * 60, time.Date(2026, 11, 1, 7, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := At(date(tt.date), tt.minute, newYork); !got.Equal(tt.want) {
+			t.Errorf("%s: At(%s, %d) = %v, want %v", tt.name, tt.date, tt.minute, got.UTC(), tt.want)
+		}
+	}
+}
+
+func TestSessionLengthAcrossDaylightSaving(t *testing.T) {
+	newYork := mustZone(t, "America/New_York")
+	tests := []struct {
+		date string
+		r    models.TimeRange
+		want time.Duration
+	}{
+		{"2026-03-08", models.TimeRange{StartTime: "01:00", EndTime: "04:00"}, 2 * time.Hour},
+		{"2026-11-01", models.TimeRange{StartTime: "00:00", EndTime: "03:00"}, 4 * time.Hour},
+		{"2026-06-01", models.TimeRange{StartTime: "01:00", EndTime: "04:00"}, 3 * time.Hour},
+	}
+	for _, tt := range tests {
+		start, end, err := Interval(date(tt.date), tt.r, newYork)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := end.Sub(start); got != tt.want {
+			t.Errorf("Interval(%s, %v) lasts %v, want %v", tt.date, tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestConvertSplit(t *testing.T) {
+	newYork := mustZone(t, "America/New_York")
+	tokyo := mustZone(t, "Asia/Tokyo")
+	tests := []struct {
+		name     string
+		date     string
+		r        models.TimeRange
+		from, to *time.Location
+		want     []Slot
+	}{
+		{
+			name: "same zone", date: "2026-06-01", r: models.TimeRange{StartTime: "10:00", EndTime: "12:00"}, from: tokyo, to: tokyo,
+			want: []Slot{{Date: date("2026-06-01"), Range: models.TimeRange{StartTime: "10:00", EndTime: "12:00"}}},
+		},
+		{
+			name: "one day", date: "2026-06-01", r: models.TimeRange{StartTime: "18:00", EndTime: "20:00"}, from: newYork, to: tokyo,
+			want: []Slot{{Date: date("2026-06-02"), Range: models.TimeRange{StartTime: "07:00", EndTime: "09:00"}}},
+		},
+		{
+			name: "crosses midnight", date: "2026-06-01", r: models.TimeRange{StartTime: "10:00", EndTime: "12:00"}, from: newYork, to: tokyo,
+			want: []Slot{
+				{Date: date("2026-06-01"), Range: models.TimeRange{StartTime: "23:00", EndTime: "24:00"}},
+				{Date: date("2026-06-02"), Range: models.TimeRange{StartTime: "00:00", EndTime: "01:00"}},
+			},
+		},
+		{
+			name: "ends at midnight", date: "2026-06-01", r: models.TimeRange{StartTime: "09:00", EndTime: "11:00"}, from: newYork, to: tokyo,
+			want: []Slot{{Date: date("2026-06-01"), Range: models.TimeRange{StartTime: "22:00", EndTime: "24:00"}}},
+		},
+		{
+			name: "into the spring-forward day", date: "2026-03-08", r: models.TimeRange{StartTime: "06:00", EndTime: "08:00"}, from: time.UTC, to: newYork,
+			want: []Slot{{Date: date("2026-03-08"), Range: models.TimeRange{StartTime: "01:00", EndTime: "04:00"}}},
+		},
+		{
+			name: "out of the spring-forward gap", date: "2026-03-08", r: models.TimeRange{StartTime: "02:30", EndTime: "04:00"}, from: newYork, to: time.UTC,
+			want: []Slot{{Date: date("2026-03-08"), Range: models.TimeRange{StartTime: "07:30", EndTime: "08:00"}}},
+		},
+	}
+	for _, tt := range tests {
+		got, err := ConvertSplit(date(tt.date), tt.r, tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ConvertSplit = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConvertSpansMidnight(t *testing.T) {
+	newYork := mustZone(t, "America/New_York")
+	tokyo := mustZone(t, "Asia/Tokyo")
+	if _, err := Convert(date("2026-06-01"), models.TimeRange{StartTime: "10:00", EndTime: "12:00"}, newYork, tokyo); !errors.Is(err, ErrSpansMidnight) {
+		t.Errorf("Convert across midnight: error = %v, want ErrSpansMidnight", err)
+	}
+	got, err := Convert(date("2026-06-01"), models.TimeRange{StartTime: "09:00", EndTime: "11:00"}, newYork, tokyo)
+	if err != nil || got != (Slot{Date: date("2026-06-01"), Range: models.TimeRange{StartTime: "22:00", EndTime: "24:00"}}) {
+		t.Errorf("Convert up to midnight = %v, %v", got, err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	newYork := mustZone(t, "America/New_York")
+	tokyo := mustZone(t, "Asia/Tokyo")
+	tests := []struct {
+		name     string
+		date     string
+		r        models.TimeRange
+		from, to *time.Location
+		wantDay  string
+		want     models.TimeRange
+	}{
+		{"crosses midnight", "2026-06-01", models.TimeRange{StartTime: "10:00", EndTime: "12:00"}, newYork, tokyo,
+			"2026-06-01", models.TimeRange{StartTime: "23:00", EndTime: "01:00"}},
+		{"previous day", "2026-06-02", models.TimeRange{StartTime: "09:00", EndTime: "10:00"}, tokyo, newYork,
+			"2026-06-01", models.TimeRange{StartTime: "20:00", EndTime: "21:00"}},
+		// Two hours on the fall-back day read as one: 01:00 EDT to 02:00 EST
+		{"fall-back day", "2026-11-01", models.TimeRange{StartTime: "05:00", EndTime: "07:00"}, time.UTC, newYork,
+			"2026-11-01", models.TimeRange{StartTime: "01:00", EndTime: "02:00"}},
+	}
+	for _, tt := range tests {
+		day, got, err := Render(date(tt.date), tt.r, tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if day.Format("2006-01-02") != tt.wantDay || got != tt.want {
+			t.Errorf("%s: Render = %s %v, want %s %v", tt.name, day.Format("2006-01-02"), got, tt.wantDay, tt.want)
+		}
+	}
+}
+
+func TestConvertDay(t *testing.T) {
+	newYork := mustZone(t, "America/New_York")
+	tokyo := mustZone(t, "Asia/Tokyo")
+	day, ranges, conflict := convertDay(date("2026-06-01"), models.TimeRanges{{StartTime: "18:00", EndTime: "19:00"}, {StartTime: "19:30", EndTime: "20:30"}}, newYork, tokyo)
+	if conflict != nil || !day.Equal(date("2026-06-02")) ||
+		!reflect.DeepEqual(ranges, models.TimeRanges{{StartTime: "07:00", EndTime: "08:00"}, {StartTime: "08:30", EndTime: "09:30"}}) {
+		t.Errorf("convertDay = %v %v %v", day, ranges, conflict)
+	}
+	// The ranges land on two days of Tokyo, so the booking cannot keep one date
+	_, _, conflict = convertDay(date("2026-06-01"), models.TimeRanges{{StartTime: "09:00", EndTime: "10:00"}, {StartTime: "11:00", EndTime: "12:00"}}, newYork, tokyo)
+	if conflict == nil || conflict.Reason != ConflictSpansMidnight {
+		t.Errorf("convertDay across days: conflict = %v, want %s", conflict, ConflictSpansMidnight)
+	}
+}
+
+func TestWholeDayIn(t *testing.T) {
+	newYork := mustZone(t, "America/New_York")
+	tokyo := mustZone(t, "Asia/Tokyo")
+	london := mustZone(t, "Europe/London")
+	tests := []struct {
+		date     string
+		from, to *time.Location
+		want     string
+	}{
+		{"2026-06-01", newYork, tokyo, "2026-06-02"},
+		{"2026-06-02", tokyo, newYork, "2026-06-01"},
+		{"2026-06-01", newYork, london, "2026-06-01"},
+		{"2026-03-08", newYork, time.UTC, "2026-03-08"},
+	}
+	for _, tt := range tests {
+		if got := wholeDayIn(date(tt.date), tt.from, tt.to).Format("2006-01-02"); got != tt.want {
+			t.Errorf("wholeDayIn(%s, %v, %v) = %s, want %s", tt.date, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
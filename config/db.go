@@ -39,11 +39,35 @@ func ConnectDB() {
 	}
 
 	// Migrate the schema
-	err = db.AutoMigrate(&models.User{}, &models.Card{}, &models.Booking{}, &models.VideoControl{}, &models.Users_ref{}, &models.TalentRegistration{}, &models.ServiceCard{}, &models.AvailableTimeSlots{}, &models.BookingRequests{})
+	err = db.AutoMigrate(&models.User{}, &models.Card{}, &models.Booking{}, &models.VideoControl{}, &models.Users_ref{}, &models.TalentRegistration{}, &models.ServiceCard{}, &models.TalentSchedulingRules{}, &models.AvailableTimeSlots{}, &models.AvailabilityTemplate{}, &models.AvailabilityException{}, &models.BookingRequests{}, &models.BookingStatusHistory{}, &models.BookingHold{}, &models.RescheduleRequest{}, &models.WaitlistEntry{}, &models.BookingSeries{}, &models.RefreshToken{}, &models.PasswordResetToken{}, &models.VerificationCode{}, &models.RecoveryCode{}, &models.AppSetting{}, &models.AuthEvent{}, &models.RateLimitHit{}, &models.RateLimitLock{}, &models.APIKey{})
 	if err != nil {
 		log.Fatal("Failed to migrate database schema:", err)
 	}
 
+	// Holds created before hold kinds existed are marked by what they belong to
+	if err := migrateHoldKinds(db); err != nil {
+		log.Fatal("Failed to migrate booking hold kinds:", err)
+	}
+
+	// Fold the legacy users table into the canonical users_refs table
+	if err := migrateLegacyUsers(db); err != nil {
+		log.Fatal("Failed to migrate legacy users:", err)
+	}
+
 	DB = db
 	fmt.Println("Database connected and schema migrated")
 }
+
+// migrateHoldKinds marks the holds behind reschedule requests and waitlist offers,
+// which took the default checkout kind when the column was added. It is a no-op
+// once they are marked.
+func migrateHoldKinds(db *gorm.DB) error {
+	if err := db.Model(&models.BookingHold{}).
+		Where("kind = ? AND hold_id IN (?)", models.HoldCheckout, db.Model(&models.RescheduleRequest{}).Select("hold_id")).
+		Update("kind", models.HoldReschedule).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.BookingHold{}).
+		Where("kind = ? AND hold_id IN (?)", models.HoldCheckout, db.Model(&models.WaitlistEntry{}).Where("offer_hold_id <> ''").Select("offer_hold_id")).
+		Update("kind", models.HoldWaitlist).Error
+}
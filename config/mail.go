@@ -0,0 +1,29 @@
+package config
+
+import (
+	"log"
+
+	"taas-api/mailer"
+)
+
+// Mailer delivers outgoing emails (password resets, verification codes, ...).
+var Mailer mailer.Mailer
+
+// SMS delivers outgoing text messages (phone verification codes).
+var SMS mailer.SMSSender
+
+// InitMailer configures Mailer and SMS from the environment. It must run after the
+// .env file has been loaded by ConnectDB.
+func InitMailer() {
+	m, err := mailer.FromEnv()
+	if err != nil {
+		log.Fatal("Failed to configure mailer:", err)
+	}
+	Mailer = m
+
+	sms, err := mailer.SMSFromEnv()
+	if err != nil {
+		log.Fatal("Failed to configure SMS sender:", err)
+	}
+	SMS = sms
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"taas-api/models"
+
+	"gorm.io/gorm"
+)
+
+// legacyUserReferences lists the columns that may still hold IDs from the legacy
+// users table and must follow an account when it is merged into users_refs.
+var legacyUserReferences = []struct {
+	Table  string
+	Column string
+}{
+	{"cards", "user_id"},
+	{"bookings", "user_id"},
+	{"bookings", "booked_by"},
+	{"booking_requests", "user_id"},
+	{"talent_registrations", "user_id"},
+	{"refresh_tokens", "user_id"},
+}
+
+// migrateLegacyUsers merges rows from the legacy users table into users_refs.
+// A legacy account whose email already exists in users_refs is folded into that
+// account and every reference to its old ID is rewritten; otherwise a new
+// users_refs row is created that keeps the legacy ID. Merged rows are marked with
+// legacy_user_id, so the migration is safe to run on every startup.
+func migrateLegacyUsers(db *gorm.DB) error {
+	var legacyUsers []models.User
+	if err := db.Find(&legacyUsers).Error; err != nil {
+		return err
+	}
+
+	merged := 0
+	for _, legacy := range legacyUsers {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var count int64
+			if err := tx.Model(&models.Users_ref{}).Where("legacy_user_id = ?", legacy.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil // Already migrated
+			}
+
+			legacyID := legacy.ID
+			var account models.Users_ref
+			err := tx.Where("LOWER(email) = LOWER(?)", legacy.Email).First(&account).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				account = models.Users_ref{
+					UserID:       legacy.ID,
+					Email:        legacy.Email,
+					Password:     legacy.Password,
+					AccountType:  models.AccountTypeUser,
+					LegacyUserID: &legacyID,
+				}
+				merged++
+				return tx.Create(&account).Error
+			}
+			if err != nil {
+				return err
+			}
+
+			// The email is already registered: keep the canonical account and its password
+			if err := tx.Model(&account).Update("legacy_user_id", legacyID).Error; err != nil {
+				return err
+			}
+			if account.UserID != legacy.ID {
+				for _, ref := range legacyUserReferences {
+					if !tx.Migrator().HasTable(ref.Table) {
+						continue
+					}
+					query := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", ref.Table, ref.Column, ref.Column)
+					if err := tx.Exec(query, account.UserID, legacy.ID).Error; err != nil {
+						return err
+					}
+				}
+			}
+			merged++
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("merging legacy user %s: %w", legacy.ID, err)
+		}
+	}
+
+	if merged > 0 {
+		log.Printf("Merged %d legacy users into users_refs\n", merged)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"taas-api/ratelimit"
+)
+
+// LoginLimiter throttles sign-in attempts per IP and per account.
+var LoginLimiter *ratelimit.Limiter
+
+// PasswordResetLimiter throttles password reset requests per IP and per email,
+// so the endpoint cannot be used to flood an inbox. Every request counts.
+var PasswordResetLimiter *ratelimit.Limiter
+
+// InitLimiter configures LoginLimiter and PasswordResetLimiter. RATE_LIMIT_STORE selects the backing store:
+// "memory" (default, single instance) or "postgres" (shared by all instances).
+// It must run after ConnectDB.
+func InitLimiter() {
+	var store ratelimit.Store
+	switch driver := os.Getenv("RATE_LIMIT_STORE"); driver {
+	case "", "memory":
+		store = ratelimit.NewMemoryStore()
+	case "postgres":
+		store = ratelimit.NewPostgresStore(DB)
+	default:
+		log.Fatalf("Unknown RATE_LIMIT_STORE %q", driver)
+	}
+	LoginLimiter = ratelimit.NewLimiter(store, ratelimit.Config{})
+	PasswordResetLimiter = ratelimit.NewLimiter(store, ratelimit.Config{
+		Window:           time.Hour,
+		FreeAttempts:     5, // No progressive delay; requests are refused once locked
+		AccountLockAfter: 5,
+		IPLockAfter:      20,
+		LockoutDuration:  time.Hour,
+		Prefix:           "password_reset:",
+	})
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// Verification requirements for creating bookings, set with BOOKING_REQUIRES_VERIFICATION.
+const (
+	VerificationNone  = ""
+	VerificationEmail = "email"
+	VerificationPhone = "phone"
+	VerificationBoth  = "both"
+)
+
+// BookingVerificationRequirement returns which contact details must be verified
+// before an account may create bookings.
+func BookingVerificationRequirement() string {
+	switch v := strings.ToLower(strings.TrimSpace(os.Getenv("BOOKING_REQUIRES_VERIFICATION"))); v {
+	case VerificationEmail, VerificationPhone, VerificationBoth:
+		return v
+	default:
+		return VerificationNone
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"taas-api/config"
+	"taas-api/middleware"
+	"taas-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateAccountType handles PATCH /api/admin/users/:user_id/account-type and lets an
+// admin change the role of any account.
+func UpdateAccountType(c *gin.Context) {
+	userID := c.Param("user_id")
+
+	var input struct {
+		AccountType string `json:"account_type" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "account_type is required"})
+		return
+	}
+
+	switch input.AccountType {
+	case models.AccountTypeUser, models.AccountTypeTalent, models.AccountTypeAdmin:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account_type. Allowed values are 'User', 'Talent' or 'Admin'"})
+		return
+	}
+
+	result := config.DB.Model(&models.Users_ref{}).Where("user_id = ?", userID).Update("account_type", input.AccountType)
+	if result.Error != nil {
+		log.Printf("Error updating account type for user %s: %v\n", userID, result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update account type"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	log.Printf("Admin %s changed account type of user %s to %s\n", middleware.UserID(c), userID, input.AccountType)
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Account type updated successfully",
+		"user_id":      userID,
+		"account_type": input.AccountType,
+	})
+}
+
+// GetMFASettings handles GET /api/admin/settings/mfa.
+func GetMFASettings(c *gin.Context) {
+	required, err := talentMFARequired(config.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read MFA settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"require_talent_mfa": required})
+}
+
+// UpdateMFASettings handles PUT /api/admin/settings/mfa and makes TOTP mandatory
+// (or optional) for every "Talent" account.
+func UpdateMFASettings(c *gin.Context) {
+	var input struct {
+		RequireTalentMFA *bool `json:"require_talent_mfa" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "require_talent_mfa is required"})
+		return
+	}
+
+	setting := models.AppSetting{
+		Key:       models.SettingRequireTalentMFA,
+		Value:     strconv.FormatBool(*input.RequireTalentMFA),
+		UpdatedBy: middleware.UserID(c),
+	}
+	if err := config.DB.Save(&setting).Error; err != nil {
+		log.Printf("Error saving MFA settings: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save MFA settings"})
+		return
+	}
+
+	log.Printf("Admin %s set %s to %s\n", setting.UpdatedBy, setting.Key, setting.Value)
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "MFA settings updated successfully",
+		"require_talent_mfa": *input.RequireTalentMFA,
+	})
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"taas-api/config"
+	"taas-api/middleware"
+	"taas-api/models"
+	"taas-api/policy"
+	"taas-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxAPIKeysPerUser caps the number of live keys one account can hold.
+const maxAPIKeysPerUser = 20
+
+// CreateAPIKey handles POST /api/api-keys. The raw key is returned only in this
+// response; afterwards only its prefix is shown.
+func CreateAPIKey(c *gin.Context) {
+	var input struct {
+		Name          string   `json:"name" binding:"required"`
+		Scopes        []string `json:"scopes" binding:"required"`
+		ExpiresInDays int      `json:"expires_in_days"` // 0 means the key does not expire
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name and scopes are required"})
+		return
+	}
+	if len(input.Scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one scope is required", "allowed_scopes": policy.APIKeyScopes})
+		return
+	}
+	scopes := make(models.StringSlice, 0, len(input.Scopes))
+	seen := make(map[string]bool)
+	for _, scope := range input.Scopes {
+		if !validAPIKeyScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scope: " + scope, "allowed_scopes": policy.APIKeyScopes})
+			return
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	if input.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days must not be negative"})
+		return
+	}
+
+	userID := middleware.UserID(c)
+	var live int64
+	if err := config.DB.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", userID).Count(&live).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+	if live >= maxAPIKeysPerUser {
+		c.JSON(http.StatusConflict, gin.H{"error": "Too many active API keys; revoke an unused one first"})
+		return
+	}
+
+	rawKey, lookup, err := utils.GenerateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+	key := models.APIKey{
+		UserID:  userID,
+		Name:    input.Name,
+		Prefix:  lookup,
+		KeyHash: utils.HashToken(rawKey),
+		Scopes:  scopes,
+	}
+	if input.ExpiresInDays > 0 {
+		expiresAt := time.Now().Add(time.Duration(input.ExpiresInDays) * 24 * time.Hour)
+		key.ExpiresAt = &expiresAt
+	}
+	if err := config.DB.Create(&key).Error; err != nil {
+		log.Printf("Error saving API key for user %s: %v\n", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "API key created; store it now, it will not be shown again",
+		"key":     rawKey,
+		"api_key": key,
+	})
+}
+
+// ListAPIKeys handles GET /api/api-keys and returns the caller's keys without secrets.
+func ListAPIKeys(c *gin.Context) {
+	var keys []models.APIKey
+	if err := config.DB.Where("user_id = ?", middleware.UserID(c)).Order("created_at DESC").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// RevokeAPIKey handles DELETE /api/api-keys/:id. Revoked keys stay listed for auditing.
+func RevokeAPIKey(c *gin.Context) {
+	var key models.APIKey
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), middleware.UserID(c)).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+	if key.RevokedAt == nil {
+		if err := config.DB.Model(&key).Update("revoked_at", time.Now()).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked", "api_key": key})
+}
+
+func validAPIKeyScope(scope string) bool {
+	for _, s := range policy.APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
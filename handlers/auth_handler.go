@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"taas-api/config"
+	"taas-api/middleware"
+	"taas-api/models"
+	"taas-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"storj.io/common/uuid"
+)
+
+// refreshTokenTTL is how long a refresh token can be used before the user has to sign in again.
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+var errRefreshTokenInvalid = errors.New("invalid refresh token")
+
+// sessionTokens is the token pair returned by every endpoint that starts or renews a session.
+type sessionTokens struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // Access token lifetime in seconds
+}
+
+// issueSession creates an access token and a new refresh token for the user.
+// An empty familyID starts a new token family (i.e. a new sign-in).
+func issueSession(db *gorm.DB, user models.Users_ref, familyID string) (sessionTokens, error) {
+	accessToken, err := utils.GenerateToken(user.UserID, user.Email, user.AccountType)
+	if err != nil {
+		return sessionTokens{}, err
+	}
+
+	if familyID == "" {
+		id, err := uuid.New()
+		if err != nil {
+			return sessionTokens{}, err
+		}
+		familyID = id.String()
+	}
+
+	rawRefresh, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return sessionTokens{}, err
+	}
+	record := models.RefreshToken{
+		UserID:    user.UserID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashToken(rawRefresh),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return sessionTokens{}, err
+	}
+
+	return sessionTokens{
+		AccessToken:  accessToken,
+		RefreshToken: rawRefresh,
+		ExpiresIn:    int(utils.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// revokeRefreshFamily revokes every live token of a refresh token family.
+func revokeRefreshFamily(db *gorm.DB, familyID string) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RefreshSession handles POST /api/token/refresh. The presented refresh token is
+// rotated: it is revoked and a new one from the same family is returned. Presenting
+// an already rotated token is treated as theft and revokes the whole family.
+func RefreshSession(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+		return
+	}
+
+	var tokens sessionTokens
+	reuseDetected := false
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", utils.HashToken(input.RefreshToken)).
+			First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errRefreshTokenInvalid
+			}
+			return err
+		}
+
+		if current.RevokedAt != nil {
+			log.Printf("Refresh token reuse detected for user %s, revoking family %s\n", current.UserID, current.FamilyID)
+			reuseDetected = true
+			return revokeRefreshFamily(tx, current.FamilyID)
+		}
+		if time.Now().After(current.ExpiresAt) {
+			return errRefreshTokenInvalid
+		}
+
+		var user models.Users_ref
+		if err := tx.Where("user_id = ?", current.UserID).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errRefreshTokenInvalid
+			}
+			return err
+		}
+
+		issued, err := issueSession(tx, user, current.FamilyID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := tx.Model(&current).Updates(map[string]interface{}{
+			"revoked_at":  now,
+			"replaced_by": utils.HashToken(issued.RefreshToken),
+		}).Error; err != nil {
+			return err
+		}
+		tokens = issued
+		return nil
+	})
+
+	if reuseDetected && err == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has already been used; all sessions from this sign-in were revoked"})
+		return
+	}
+	if err != nil {
+		if errors.Is(err, errRefreshTokenInvalid) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+			return
+		}
+		log.Printf("Error refreshing session: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout handles POST /api/logout by revoking the session the refresh token belongs to.
+func Logout(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+		return
+	}
+
+	var token models.RefreshToken
+	if err := config.DB.Where("token_hash = ?", utils.HashToken(input.RefreshToken)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Nothing to revoke; logging out is idempotent
+			c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	if err := revokeRefreshFamily(config.DB, token.FamilyID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll handles POST /api/logout-all by revoking every refresh token of the
+// authenticated user. Outstanding access tokens expire on their own within AccessTokenTTL.
+func LogoutAll(c *gin.Context) {
+	userID := middleware.UserID(c)
+
+	if err := config.DB.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out of all sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
+}
@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"taas-api/booking"
+	"taas-api/config"
+	"taas-api/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Weekly templates and date exceptions are written on the talent's wall clock:
+// "09:00" is nine in the morning wherever the talent's time zone says, on every
+// date, and stays so when the zone changes.
+
+// CreateAvailabilityTemplateRequest is a weekly template such as Monday to
+// Friday 09:00-12:00 and 14:00-18:00.
+type CreateAvailabilityTemplateRequest struct {
+	Weekdays       []int             `json:"weekdays" binding:"required"` // 0 = Sunday ... 6 = Saturday
+	Slots          models.TimeRanges `json:"slots" binding:"required"`
+	EffectiveFrom  string            `json:"effective_from" binding:"required"` // YYYY-MM-DD
+	EffectiveUntil string            `json:"effective_until"`                   // YYYY-MM-DD; open-ended when empty
+}
+
+// AvailabilityExceptionRequest overrides or blacks out one date.
+type AvailabilityExceptionRequest struct {
+	Kind   string            `json:"kind" binding:"required"` // override or blackout
+	Slots  models.TimeRanges `json:"slots"`                   // Replacement slots of an override; none means unavailable
+	Reason string            `json:"reason"`
+}
+
+// CreateAvailabilityTemplate handles POST /api/talents/:talent_id/availability-templates.
+// Existing bookings are not affected; users waiting for a date the template
+// opens up are offered the new time.
+func CreateAvailabilityTemplate(c *gin.Context) {
+	talentID := c.Param("talent_id")
+	if !authorizeTalent(c, talentID) {
+		return
+	}
+
+	var req CreateAvailabilityTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	var weekdays models.Weekdays
+	for _, d := range req.Weekdays {
+		if d < 0 || d > 6 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "weekdays must be 0 (Sunday) to 6 (Saturday)"})
+			return
+		}
+		if !weekdays.Has(time.Weekday(d)) {
+			weekdays = append(weekdays, time.Weekday(d))
+		}
+	}
+	if len(weekdays) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one weekday is required"})
+		return
+	}
+	slots, ok := normalizeSlots(c, req.Slots, true)
+	if !ok {
+		return
+	}
+
+	effectiveFrom, err := time.Parse("2006-01-02", req.EffectiveFrom)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid effective_from. Use YYYY-MM-DD."})
+		return
+	}
+	template := models.AvailabilityTemplate{
+		TalentID:      talentID,
+		Weekdays:      weekdays,
+		Slots:         slots,
+		EffectiveFrom: effectiveFrom,
+	}
+	if req.EffectiveUntil != "" {
+		effectiveUntil, err := time.Parse("2006-01-02", req.EffectiveUntil)
+		if err != nil || effectiveUntil.Before(effectiveFrom) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "effective_until must be a YYYY-MM-DD date on or after effective_from"})
+			return
+		}
+		template.EffectiveUntil = &effectiveUntil
+	}
+
+	if err := config.DB.Create(&template).Error; err != nil {
+		log.Printf("Error creating availability template for talent %s: %v\n", talentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create availability template"})
+		return
+	}
+	booking.InvalidateSnapshot(talentID)
+	offerTemplateTime(template)
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Availability template created", "data": template})
+}
+
+// ListAvailabilityTemplates handles GET /api/talents/:talent_id/availability-templates.
+func ListAvailabilityTemplates(c *gin.Context) {
+	talentID := c.Param("talent_id")
+	var templates []models.AvailabilityTemplate
+	if err := config.DB.Where("talent_id = ?", talentID).Order("effective_from, id").Find(&templates).Error; err != nil {
+		log.Printf("Error fetching availability templates of talent %s: %v\n", talentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch availability templates"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"talent_id": talentID, "data": templates})
+}
+
+// DeleteAvailabilityTemplate handles DELETE /api/talents/:talent_id/availability-templates/:id.
+// A template whose time is booked on a coming date cannot be deleted.
+func DeleteAvailabilityTemplate(c *gin.Context) {
+	talentID := c.Param("talent_id")
+	if !authorizeTalent(c, talentID) {
+		return
+	}
+
+	var conflicts []booking.Conflict
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var template models.AvailabilityTemplate
+		if err := tx.Where("id = ? AND talent_id = ?", c.Param("id"), talentID).First(&template).Error; err != nil {
+			return err
+		}
+		var until time.Time
+		if template.EffectiveUntil != nil {
+			until = *template.EffectiveUntil
+		}
+		var err error
+		conflicts, err = booking.ProtectBookedTime(tx, talentID, template.EffectiveFrom, until, time.Now(), func(tx *gorm.DB) error {
+			return tx.Delete(&template).Error
+		})
+		if err == nil && len(conflicts) > 0 {
+			return errAvailabilityBooked
+		}
+		return err
+	})
+	if !answerAvailabilityChange(c, talentID, err, conflicts, "Availability template not found") {
+		return
+	}
+	booking.InvalidateSnapshot(talentID)
+	c.JSON(http.StatusOK, gin.H{"message": "Availability template deleted"})
+}
+
+// PutAvailabilityException handles PUT /api/talents/:talent_id/availability-exceptions/:date.
+// An override replaces the templates' slots on the date, a blackout removes all
+// availability including one-off slots. Neither may take away booked time.
+func PutAvailabilityException(c *gin.Context) {
+	talentID := c.Param("talent_id")
+	if !authorizeTalent(c, talentID) {
+		return
+	}
+	date, err := time.Parse("2006-01-02", c.Param("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+		return
+	}
+
+	var req AvailabilityExceptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+	exception := models.AvailabilityException{TalentID: talentID, Date: date, Kind: req.Kind, Reason: req.Reason}
+	switch req.Kind {
+	case models.ExceptionBlackout:
+		if len(req.Slots) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A blackout has no slots"})
+			return
+		}
+	case models.ExceptionOverride:
+		slots, ok := normalizeSlots(c, req.Slots, false)
+		if !ok {
+			return
+		}
+		exception.Slots = slots
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be override or blackout"})
+		return
+	}
+
+	var conflicts []booking.Conflict
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		conflicts, err = booking.ProtectBookedTime(tx, talentID, date, date, time.Now(), func(tx *gorm.DB) error {
+			return tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "talent_id"}, {Name: "date"}},
+				DoUpdates: clause.AssignmentColumns([]string{"kind", "slots", "reason", "updated_at"}),
+			}).Create(&exception).Error
+		})
+		if err == nil && len(conflicts) > 0 {
+			return errAvailabilityBooked
+		}
+		return err
+	})
+	if !answerAvailabilityChange(c, talentID, err, conflicts, "") {
+		return
+	}
+	booking.InvalidateSnapshot(talentID)
+	if len(exception.Slots) > 0 {
+		offerFreedTime(talentID, date, exception.Slots)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Availability exception saved", "data": exception})
+}
+
+// ListAvailabilityExceptions handles GET /api/talents/:talent_id/availability-exceptions,
+// optionally limited by from and to dates (YYYY-MM-DD).
+func ListAvailabilityExceptions(c *gin.Context) {
+	talentID := c.Param("talent_id")
+	query := config.DB.Where("talent_id = ?", talentID)
+	for param, condition := range map[string]string{"from": "date >= ?", "to": "date <= ?"} {
+		if value := c.Query(param); value != "" {
+			date, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + " date. Use YYYY-MM-DD."})
+				return
+			}
+			query = query.Where(condition, date)
+		}
+	}
+
+	var exceptions []models.AvailabilityException
+	if err := query.Order("date").Find(&exceptions).Error; err != nil {
+		log.Printf("Error fetching availability exceptions of talent %s: %v\n", talentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch availability exceptions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"talent_id": talentID, "data": exceptions})
+}
+
+// DeleteAvailabilityException handles DELETE /api/talents/:talent_id/availability-exceptions/:date;
+// the date follows the weekly templates again, unless that takes away booked time.
+func DeleteAvailabilityException(c *gin.Context) {
+	talentID := c.Param("talent_id")
+	if !authorizeTalent(c, talentID) {
+		return
+	}
+	date, err := time.Parse("2006-01-02", c.Param("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+		return
+	}
+
+	var conflicts []booking.Conflict
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var exception models.AvailabilityException
+		if err := tx.Where("talent_id = ? AND date = ?", talentID, date).First(&exception).Error; err != nil {
+			return err
+		}
+		var err error
+		conflicts, err = booking.ProtectBookedTime(tx, talentID, date, date, time.Now(), func(tx *gorm.DB) error {
+			return tx.Delete(&exception).Error
+		})
+		if err == nil && len(conflicts) > 0 {
+			return errAvailabilityBooked
+		}
+		return err
+	})
+	if !answerAvailabilityChange(c, talentID, err, conflicts, "Availability exception not found") {
+		return
+	}
+
+	booking.InvalidateSnapshot(talentID)
+	// Time the templates give back on the date may be wanted by the waitlist
+	available, err := booking.AvailabilityOn(config.DB, talentID, []time.Time{date})
+	if err == nil && len(available[c.Param("date")]) > 0 {
+		offerFreedTime(talentID, date, available[c.Param("date")])
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Availability exception deleted"})
+}
+
+// answerAvailabilityChange answers a failed change of templates or exceptions and
+// reports whether it succeeded. notFound is the message for a missing record.
+func answerAvailabilityChange(c *gin.Context, talentID string, err error, conflicts []booking.Conflict, notFound string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, errAvailabilityBooked):
+		talentZone, requesterZone, zoneErr := requestZones(c, talentID, "")
+		if zoneErr != nil {
+			respondZoneError(c, zoneErr)
+			return false
+		}
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "Some of the time you are removing is already booked; reschedule or cancel those bookings first",
+			"conflicts": renderConflicts(conflicts, talentZone, requesterZone),
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound) && notFound != "":
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+	case errors.Is(err, booking.ErrTalentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Talent not found"})
+	default:
+		log.Printf("Error changing availability of talent %s: %v\n", talentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save availability"})
+	}
+	return false
+}
+
+// normalizeSlots sorts and merges slots, answering the request itself when a
+// slot is invalid or, with required set, there are none.
+func normalizeSlots(c *gin.Context, slots models.TimeRanges, required bool) (models.TimeRanges, bool) {
+	if required && len(slots) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one time slot is required"})
+		return nil, false
+	}
+	normalized, err := booking.NormalizeRanges(slots)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time slots: " + err.Error()})
+		return nil, false
+	}
+	return normalized, true
+}
+
+// offerTemplateTime offers the time a new template opens up to the users waiting
+// for one of its dates.
+func offerTemplateTime(template models.AvailabilityTemplate) {
+	query := config.DB.Model(&models.WaitlistEntry{}).
+		Where("talent_id = ? AND status = ? AND wait_date >= ?", template.TalentID, models.WaitlistWaiting, template.EffectiveFrom)
+	if template.EffectiveUntil != nil {
+		query = query.Where("wait_date <= ?", *template.EffectiveUntil)
+	}
+	var dates []time.Time
+	if err := query.Distinct().Pluck("wait_date", &dates).Error; err != nil {
+		log.Printf("Error fetching waitlisted dates of talent %s: %v\n", template.TalentID, err)
+		return
+	}
+	for _, date := range dates {
+		if template.Weekdays.Has(date.UTC().Weekday()) {
+			offerFreedTime(template.TalentID, date, template.Slots)
+		}
+	}
+}
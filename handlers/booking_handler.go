@@ -2,34 +2,125 @@ package handlers
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strings"
+	"taas-api/booking"
 	"taas-api/config"
+	"taas-api/middleware"
 	"taas-api/models"
+	"taas-api/policy"
+	"taas-api/scheduling"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"storj.io/common/uuid"
 )
 
 type CreateBookingRequest struct {
-	CardID          string `json:"card_id" binding:"required"`        // Card ID
-	CardTitle       string `json:"card_title" binding:"required"`     // Card Title
-	UserID          string `json:"user_id" binding:"required"`        // User ID
-	TalentID        string `json:"talent_id" binding:"required"`      // Talent ID
-	SessionType     string `json:"session_type" binding:"required"`   // Enum: CoffeeCall, Regular
-	Status          string `json:"status" binding:"required"`         // Enum: Scheduled, Completed, Cancelled
-	PaymentStatus   string `json:"payment_status" binding:"required"` // Enum: Paid, Pending
-	SpecialRequests string `json:"special_requests,omitempty"`        // Optional
-	CardDuration    int    `json:"card_duration" binding:"required"`  // Card Duration (in minutes)
-
-	Slots []struct {
-		BookingDate string   `json:"booking_date" binding:"required"`
-		TimeSlots   []string `json:"time_slots" binding:"required"`
-	} `json:"slots" binding:"required"`
+	CardID          string `json:"card_id" binding:"required"`      // Card ID; the title and duration come from the card
+	UserID          string `json:"-"`                               // User ID, taken from the access token
+	TalentID        string `json:"talent_id" binding:"required"`    // Talent ID
+	SessionType     string `json:"session_type" binding:"required"` // Enum: CoffeeCall, Regular
+	SpecialRequests string `json:"special_requests,omitempty"`      // Optional
+	TimeZone        string `json:"time_zone"`                       // Zone of the slots; defaults to the user's
+
+	Slots []BookingSlotInput `json:"slots" binding:"required"`
+}
+
+// BookingSlotInput is one booking date with its "HH:MM-HH:MM" time slots.
+type BookingSlotInput struct {
+	BookingDate string   `json:"booking_date" binding:"required"`
+	TimeSlots   []string `json:"time_slots" binding:"required"`
+}
+
+// bookingDay is a parsed BookingSlotInput.
+type bookingDay struct {
+	Date   time.Time
+	Ranges models.TimeRanges
+}
+
+// parseBookingSlots validates the requested slots, given on the wall clock of
+// from, moves them onto the talent's wall clock to and returns them per day and as
+// a flat list for booking.CheckSlots. The error text is safe to show to clients.
+func parseBookingSlots(inputs []BookingSlotInput, from, to *time.Location) ([]bookingDay, []booking.Slot, error) {
+	var days []bookingDay
+	var slots []booking.Slot
+	dayIndex := make(map[string]int)
+	for _, slot := range inputs {
+		bookingDate, err := time.Parse("2006-01-02", slot.BookingDate)
+		if err != nil {
+			fmt.Println("Invalid booking_date format:", slot.BookingDate, "Error:", err)
+			return nil, nil, errors.New("Invalid booking_date format")
+		}
+
+		//iterate through all the time slots for the date
+		for _, timeSlot := range slot.TimeSlots {
+			interval, err := scheduling.ParseRange(timeSlot)
+			if errors.Is(err, scheduling.ErrEmptyInterval) {
+				return nil, nil, errors.New("End time must be after start time: " + timeSlot)
+			}
+			if err != nil {
+				fmt.Println("Invalid time_slot format:", timeSlot, "Error:", err)
+				return nil, nil, errors.New("Invalid time_slot format")
+			}
+			timeRange := booking.RangesOf([]scheduling.Interval{interval})[0]
+			// A slot may land on another day in the talent's zone
+			slot, err := booking.Convert(bookingDate, timeRange, from, to)
+			if err != nil {
+				return nil, nil, errors.New("Time slot crosses midnight in the talent's time zone: " + timeSlot)
+			}
+			key := slot.Date.Format("2006-01-02")
+			if _, ok := dayIndex[key]; !ok {
+				dayIndex[key] = len(days)
+				days = append(days, bookingDay{Date: slot.Date})
+			}
+			days[dayIndex[key]].Ranges = append(days[dayIndex[key]].Ranges, slot.Range)
+			slots = append(slots, slot)
+		}
+	}
+	if len(slots) == 0 {
+		return nil, nil, errors.New("At least one time slot is required")
+	}
+	return days, slots, nil
+}
+
+// bookingCard loads the card being booked, which must be one of the talent's,
+// and answers the request itself when it is not.
+func bookingCard(c *gin.Context, cardID, talentID string) (models.ServiceCard, bool) {
+	var card models.ServiceCard
+	err := config.DB.Where("card_id = ? AND talent_id = ?", cardID, talentID).First(&card).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Card not found for this talent"})
+		return card, false
+	}
+	if err != nil {
+		log.Printf("Error fetching card %s of talent %s: %v\n", cardID, talentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch card"})
+		return card, false
+	}
+	return card, true
+}
+
+// checkSlotLengths rejects slots that are not exactly one session of the card
+// long. parseBookingSlots must have accepted the inputs.
+func checkSlotLengths(inputs []BookingSlotInput, card models.ServiceCard) error {
+	for _, input := range inputs {
+		for _, timeSlot := range input.TimeSlots {
+			interval, err := scheduling.ParseRange(timeSlot)
+			if err != nil {
+				return errors.New("Invalid time_slot format")
+			}
+			if interval.Len() != card.Duration {
+				return fmt.Errorf("Time slot %s must be %d minutes long", timeSlot, card.Duration)
+			}
+		}
+	}
+	return nil
 }
 
 // Handler for POST /bookings to create a new booking
@@ -56,44 +147,45 @@ func CreateBookings(c *gin.Context) {
 		return
 	}
 
-	fmt.Println("Parsed Request Data:", req)
+	// The booking is always made on behalf of the authenticated user
+	req.UserID = middleware.UserID(c)
 
-	// Iterate through each slot and create a booking record
-	for _, slot := range req.Slots {
-		bookingDate, err := time.Parse("2006-01-02", slot.BookingDate)
-		if err != nil {
-			fmt.Println("Invalid booking_date format:", slot.BookingDate, "Error:", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking_date format"})
-			return
-		}
+	// Accounts may have to verify their contact details before booking
+	var user models.Users_ref
+	if err := config.DB.Where("user_id = ?", req.UserID).First(&user).Error; err != nil {
+		fmt.Println("Error fetching booking user:", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	if missing := missingBookingVerification(user); len(missing) > 0 {
+		policy.Forbid(c, "Please verify your "+strings.Join(missing, " and ")+" before booking")
+		return
+	}
 
-		var timeRanges models.TimeRanges
-		//iterate through all the time slots for the date
-		for _, timeSlot := range slot.TimeSlots {
-			startTime, endTime, err := parseTimeRange(timeSlot)
-			if err != nil {
-				fmt.Println("Invalid time_slot format:", timeSlot, "Error:", err)
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time_slot format"})
-				return
-			}
-			parsedStartTime, err := time.Parse("15:04", startTime)
-			if err != nil {
-				fmt.Println("Error parsing start time", startTime, err)
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start time format"})
-				return
-			}
-			parsedEndTime, err := time.Parse("15:04", endTime)
-			if err != nil {
-				fmt.Println("Error parsing end time", endTime, err)
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end time format"})
-				return
-			}
-			timeRanges = append(timeRanges, models.TimeRange{
-				StartTime: parsedStartTime.Format("15:04"),
-				EndTime:   parsedEndTime.Format("15:04"),
-			})
-		}
+	fmt.Println("Parsed Request Data:", req)
+
+	// Parse every slot first so that a bad one rejects the whole request
+	talentZone, requesterZone, err := requestZones(c, req.TalentID, req.TimeZone)
+	if err != nil {
+		respondZoneError(c, err)
+		return
+	}
+	days, slots, err := parseBookingSlots(req.Slots, requesterZone, talentZone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	card, ok := bookingCard(c, req.CardID, req.TalentID)
+	if !ok {
+		return
+	}
+	if err := checkSlotLengths(req.Slots, card); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
+	var newBookings []models.BookingRequests
+	for _, day := range days {
 		// Generate a new UUID for the BookingID
 		bookingID, err := uuid.New()
 		if err != nil {
@@ -101,44 +193,62 @@ func CreateBookings(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate booking ID"})
 			return
 		}
-		fmt.Println("Generated BookingID:", bookingID.String())
 
 		// Map the request data to the Bookings model
-		newBooking := models.BookingRequests{
+		newBookings = append(newBookings, models.BookingRequests{
 			BookingID:       bookingID.String(),
 			CardID:          req.CardID,
-			CardTitle:       req.CardTitle,
+			CardTitle:       card.CardTitle,
 			UserID:          req.UserID,
 			TalentID:        req.TalentID,
 			SessionType:     models.SessionType(req.SessionType),
-			BookedTime:      timeRanges,
-			Status:          models.BookingStatus(req.Status),
-			PaymentStatus:   models.PaymentStatus(req.PaymentStatus),
+			BookedTime:      day.Ranges,
+			Status:          models.Requested, // Every booking starts as a request; see booking.Transition
+			PaymentStatus:   models.Pending,   // Only the payment flow marks a booking Paid
 			SpecialRequests: req.SpecialRequests,
-			BookingDate:     bookingDate,
+			BookingDate:     day.Date,
 			CreatedAt:       time.Now(),
 			UpdatedAt:       time.Now(),
+		})
+	}
+
+	// Reserve all slots in one transaction: either every booking is created or none
+	var conflicts []booking.Conflict
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := booking.LockTalent(tx, req.TalentID); err != nil {
+			return err
 		}
-		fmt.Println("New Booking Data:", newBooking)
-		// Create the booking in the database
-		if err := config.DB.Create(&newBooking).Error; err != nil {
-			fmt.Println("Database Error:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
-			return
+		var err error
+		conflicts, err = booking.CheckSlots(tx, req.TalentID, req.CardID, req.UserID, slots)
+		if err != nil || len(conflicts) > 0 {
+			return err
 		}
-
-		fmt.Println("Booking created successfully:", newBooking)
-
+		for i := range newBookings {
+			if err := booking.Create(tx, &newBookings[i], booking.Actor{ID: req.UserID, Role: booking.ActorUser}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, booking.ErrTalentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Talent not found"})
+		return
 	}
-	// Respond with a success message
-	c.JSON(http.StatusCreated, gin.H{"message": "Bookings created successfully"})
-}
-func parseTimeRange(timeRange string) (string, string, error) {
-	parts := strings.Split(timeRange, "-")
-	if len(parts) != 2 {
-		return "", "", fmt.Errorf("invalid time range format")
+	if err != nil {
+		fmt.Println("Database Error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+		return
+	}
+	if len(conflicts) > 0 {
+		fmt.Println("Booking conflicts:", conflicts)
+		c.JSON(http.StatusConflict, gin.H{"error": "Some of the selected slots are no longer available", "conflicts": renderConflicts(conflicts, talentZone, requesterZone)})
+		return
 	}
-	return parts[0], parts[1], nil
+
+	booking.InvalidateSnapshot(req.TalentID)
+	fmt.Println("Bookings created successfully:", len(newBookings))
+	// Respond with a success message
+	c.JSON(http.StatusCreated, gin.H{"message": "Bookings created successfully", "bookings": newBookings})
 }
 
 // Handler for GET /bookings/user/:user_id to fetch all bookings made by a user
@@ -154,6 +264,9 @@ func GetBookingsByUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Talent ID is required"})
 		return
 	}
+	if !authorizeUser(c, userId) {
+		return
+	}
 
 	// Fetch bookings from the database based on talent_id
 	log.Printf("Fetching bookings from database for talent_id: %s\n", userId)
@@ -186,6 +299,9 @@ func GetBookingsByTalent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Talent ID is required"})
 		return
 	}
+	if !authorizeTalent(c, talentID) {
+		return
+	}
 
 	// Fetch bookings from the database based on talent_id
 	log.Printf("Fetching bookings from database for talent_id: %s\n", talentID)
@@ -204,3 +320,193 @@ func GetBookingsByTalent(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"bookings": bookings})
 	log.Println("Successfully completed GetBookingsByTalent handler")
 }
+
+// bookingActorRoles returns the roles in which the authenticated caller acts on b.
+// A caller can hold several, e.g. an admin who also made the booking.
+func bookingActorRoles(c *gin.Context, b models.BookingRequests) ([]string, error) {
+	userID := middleware.UserID(c)
+	var roles []string
+	ownsTalent, err := policy.OwnsTalent(config.DB, userID, b.TalentID)
+	if err != nil {
+		return nil, err
+	}
+	if ownsTalent {
+		roles = append(roles, booking.ActorTalent)
+	}
+	if b.UserID == userID {
+		roles = append(roles, booking.ActorUser)
+	}
+	if middleware.AccountType(c) == models.AccountTypeAdmin {
+		roles = append(roles, booking.ActorAdmin)
+	}
+	return roles, nil
+}
+
+// UpdateBookingRequestStatus handles PATCH /api/bookings/:id/status and moves a
+// booking through its lifecycle, e.g. {"status": "Accepted", "reason": "..."}.
+func UpdateBookingRequestStatus(c *gin.Context) {
+	var input struct {
+		Status string `json:"status" binding:"required"`
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: 'status' is required"})
+		return
+	}
+	to := models.BookingStatus(input.Status)
+
+	var existing models.BookingRequests
+	if err := config.DB.Where("booking_id = ?", c.Param("id")).First(&existing).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+	roles, err := bookingActorRoles(c, existing)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update booking status"})
+		return
+	}
+	if len(roles) == 0 {
+		policy.Forbid(c, "You are not allowed to update this booking")
+		return
+	}
+
+	// Act in the first role that is allowed to make this move
+	actor := booking.Actor{ID: middleware.UserID(c), Role: roles[0]}
+	for _, role := range roles {
+		if booking.CanTransition(existing.Status, to, role) {
+			actor.Role = role
+			break
+		}
+	}
+
+	// Cancellations also settle the payment
+	if to == models.Cancelled {
+		cancelBooking(c, existing.BookingID, actor, input.Reason)
+		return
+	}
+
+	var updated models.BookingRequests
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		updated, err = booking.Transition(tx, existing.BookingID, to, actor, input.Reason)
+		return err
+	})
+	if err != nil {
+		respondTransitionError(c, err, actor)
+		return
+	}
+	booking.InvalidateSnapshot(updated.TalentID)
+	c.JSON(http.StatusOK, gin.H{"message": "Booking status updated successfully", "booking": updated})
+}
+
+// CancelBooking handles POST /api/bookings/:id/cancel for the booker, the talent
+// and admins. The refund follows the card's cancellation policy for the booker;
+// cancellations by the talent are refunded in full. With ?scope=future all later
+// occurrences of the booking's series are cancelled as well.
+func CancelBooking(c *gin.Context) {
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var existing models.BookingRequests
+	if err := config.DB.Where("booking_id = ?", c.Param("id")).First(&existing).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+	roles, err := bookingActorRoles(c, existing)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel booking"})
+		return
+	}
+	if len(roles) == 0 {
+		policy.Forbid(c, "You are not allowed to cancel this booking")
+		return
+	}
+
+	actor := booking.Actor{ID: middleware.UserID(c), Role: roles[0]}
+	for _, role := range roles {
+		if booking.CanTransition(existing.Status, models.Cancelled, role) {
+			actor.Role = role
+			break
+		}
+	}
+
+	scope, ok := seriesScope(c, existing)
+	if !ok {
+		return
+	}
+	if scope == seriesScopeFuture {
+		cancelFutureOccurrences(c, existing, actor, input.Reason)
+		return
+	}
+	cancelBooking(c, existing.BookingID, actor, input.Reason)
+}
+
+// cancelBooking runs booking.Cancel and writes the response.
+func cancelBooking(c *gin.Context, bookingID string, actor booking.Actor, reason string) {
+	var cancelled models.BookingRequests
+	var refund booking.CancelResult
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		cancelled, refund, err = booking.Cancel(tx, bookingID, actor, reason, time.Now())
+		return err
+	})
+	if err != nil {
+		respondTransitionError(c, err, actor)
+		return
+	}
+	booking.InvalidateSnapshot(cancelled.TalentID)
+	// The freed time goes to the first users on the waitlist
+	offerFreedTime(cancelled.TalentID, cancelled.BookingDate, cancelled.BookedTime)
+	c.JSON(http.StatusOK, gin.H{"message": "Booking cancelled", "booking": cancelled, "refund": refund})
+}
+
+// respondTransitionError maps errors of the booking package to responses.
+func respondTransitionError(c *gin.Context, err error, actor booking.Actor) {
+	var illegal *booking.TransitionError
+	switch {
+	case errors.As(err, &illegal):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   illegal.Error(),
+			"status":  illegal.From,
+			"allowed": booking.AllowedTransitions(illegal.From, actor.Role),
+		})
+	case errors.Is(err, booking.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+	default:
+		log.Printf("Error updating status of booking: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update booking status"})
+	}
+}
+
+// GetBookingStatusHistory handles GET /api/bookings/:id/history for the booker,
+// the talent owner and admins.
+func GetBookingStatusHistory(c *gin.Context) {
+	var existing models.BookingRequests
+	if err := config.DB.Where("booking_id = ?", c.Param("id")).First(&existing).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+	roles, err := bookingActorRoles(c, existing)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch booking history"})
+		return
+	}
+	if len(roles) == 0 {
+		policy.Forbid(c, "You are not allowed to view this booking")
+		return
+	}
+
+	var history []models.BookingStatusHistory
+	if err := config.DB.Where("booking_id = ?", existing.BookingID).Order("created_at, id").Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch booking history"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"booking_id": existing.BookingID, "status": existing.Status, "history": history})
+}
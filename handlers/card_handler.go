@@ -5,7 +5,9 @@ import (
 	"net/http"
 
 	"taas-api/config"
+	"taas-api/middleware"
 	"taas-api/models"
+	"taas-api/policy"
 
 	"github.com/gin-gonic/gin"
 
@@ -34,13 +36,18 @@ func CreateServiceCard(c *gin.Context) {
 
 	var card struct {
 		// Primary Key
-		TalentID        string `gorm:"not null" json:"talent_id"`                  // Foreign Key: Links to Talent table
-		CardTitle       string `gorm:"not null" json:"card_title"`                 // Title of the service
-		CardDescription string `gorm:"type:text;not null" json:"card_description"` // Detailed description
-		Suit            string `gorm:"not null" json:"suit"`                       // Enum: "Heart", "Spade", "Diamond", "Clover"
-		Price           int    `    gorm:"not null" json:"price"`                  // Price for the service
-		Duration        int    `gorm:"not null" json:"duration"`                   // Duration in minutes
-		Tags            string `gorm:"not null" json:"tags"`                       // Comma-separated tags
+		TalentID                string                    `gorm:"not null" json:"talent_id"`                  // Foreign Key: Links to Talent table
+		CardTitle               string                    `gorm:"not null" json:"card_title"`                 // Title of the service
+		CardDescription         string                    `gorm:"type:text;not null" json:"card_description"` // Detailed description
+		Suit                    string                    `gorm:"not null" json:"suit"`                       // Enum: "Heart", "Spade", "Diamond", "Clover"
+		Price                   int                       `    gorm:"not null" json:"price"`                  // Price for the service
+		Duration                int                       `gorm:"not null" json:"duration"`                   // Duration in minutes
+		Tags                    string                    `gorm:"not null" json:"tags"`                       // Comma-separated tags
+		CancellationPolicy      models.CancellationPolicy `json:"cancellation_policy"`                        // Optional refund tiers
+		MaxReschedules          *int                      `json:"max_reschedules"`                            // Optional; defaults to models.DefaultMaxReschedules
+		RescheduleApproval      bool                      `json:"reschedule_approval"`                        // Reschedules by the booker need approval
+		Capacity                int                       `json:"capacity"`                                   // Users per session; defaults to 1
+		schedulingRuleOverrides                           // Optional per-card buffers, notice and horizon
 	}
 
 	// Parse the request body into the input struct
@@ -50,6 +57,29 @@ func CreateServiceCard(c *gin.Context) {
 	}
 
 	fmt.Println("inputs", card)
+
+	if err := card.CancellationPolicy.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cancellation_policy: " + err.Error()})
+		return
+	}
+	if card.Capacity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "capacity must be at least 1"})
+		return
+	}
+	if card.MaxReschedules != nil && *card.MaxReschedules < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_reschedules must not be negative"})
+		return
+	}
+	if _, ok := card.schedulingRuleOverrides.columns(false); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": schedulingRulesError})
+		return
+	}
+
+	// Only the owner of the talent account may publish cards for it
+	if !authorizeTalent(c, card.TalentID) {
+		return
+	}
+
 	//Generate a unique user ID using UUID
 	CardId, err := uuid.New()
 	if err != nil {
@@ -58,14 +88,22 @@ func CreateServiceCard(c *gin.Context) {
 	}
 
 	cards := models.ServiceCard{
-		CardID:          CardId.String(),
-		TalentID:        card.TalentID,
-		CardTitle:       card.CardTitle,
-		CardDescription: card.CardDescription,
-		Suit:            card.Suit,
-		Price:           card.Price,
-		Duration:        card.Duration,
-		Tags:            card.Tags,
+		CardID:              CardId.String(),
+		TalentID:            card.TalentID,
+		CardTitle:           card.CardTitle,
+		CardDescription:     card.CardDescription,
+		Suit:                card.Suit,
+		Price:               card.Price,
+		Duration:            card.Duration,
+		Tags:                card.Tags,
+		CancellationPolicy:  card.CancellationPolicy,
+		MaxReschedules:      card.MaxReschedules,
+		RescheduleApproval:  card.RescheduleApproval,
+		Capacity:            card.Capacity,
+		BufferBeforeMinutes: card.BufferBeforeMinutes,
+		BufferAfterMinutes:  card.BufferAfterMinutes,
+		MinNoticeMinutes:    card.MinNoticeMinutes,
+		MaxDaysInAdvance:    card.MaxDaysInAdvance,
 	}
 
 	// Save the card to the database
@@ -124,9 +162,9 @@ func SaveCard(c *gin.Context) {
 
 	// 2. Parse EventDate and EventTime
 
-	// 3. Create a new Card instance
+	// 3. Create a new Card instance owned by the authenticated user
 	card := models.Card{
-		UserID:       input.UserID,
+		UserID:       middleware.UserID(c),
 		Title:        input.Title,
 		Description:  input.Description,
 		Category:     input.Category,
@@ -153,10 +191,11 @@ func SaveCard(c *gin.Context) {
 // GetUserCards handles fetching all cards for a specific user
 func GetUserCards(c *gin.Context) {
 	// 1. Extract UserID from query parameters
-	userID := c.Query("user_id") // Assumes the user ID is sent as a query parameter
-
+	userID := c.Query("user_id") // Defaults to the signed-in user
 	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+		userID = middleware.UserID(c)
+	}
+	if !authorizeUser(c, userID) {
 		return
 	}
 
@@ -293,11 +332,11 @@ func RetrieveMyBookedCards(c *gin.Context) {
 // RetrieveMyBookedCards handles fetching all booked cards for a specific user
 func RetrieveMyBookedCardsRequestToTalent(c *gin.Context) {
 	// 1. Extract 'booked_by' from query parameters
-	talentId := c.Query("userId")
-
-	// Validate the input
+	talentId := c.Query("userId") // Defaults to the signed-in user
 	if talentId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID (card creator) is required"})
+		talentId = middleware.UserID(c)
+	}
+	if !authorizeUser(c, talentId) {
 		return
 	}
 
@@ -316,19 +355,15 @@ func RetrieveMyBookedCardsRequestToTalent(c *gin.Context) {
 }
 
 func HandleUpdateBookingStatus(c *gin.Context) {
-	// Extract booking ID and user ID from query parameters
+	// Extract booking ID from query parameters and user ID from the token
 	bookingID := c.Query("bookingId")
-	userID := c.Query("user_id")
+	userID := middleware.UserID(c)
 
-	// Validate the booking ID and user ID
+	// Validate the booking ID
 	if bookingID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Booking ID is required"})
 		return
 	}
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
-		return
-	}
 
 	// Input for status update
 	type StatusUpdateInput struct {
@@ -356,6 +391,12 @@ func HandleUpdateBookingStatus(c *gin.Context) {
 		return
 	}
 
+	// Only the card creator (or an admin) may accept or decline a booking
+	if booking.UserID != userID && middleware.AccountType(c) != models.AccountTypeAdmin {
+		policy.Forbid(c, "You are not allowed to update this booking")
+		return
+	}
+
 	// Update the status
 	booking.Status = input.Status
 	if err := config.DB.Save(&booking).Error; err != nil {
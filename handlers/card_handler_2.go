@@ -3,6 +3,7 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"taas-api/booking"
 	"taas-api/config"
 	"taas-api/models"
 
@@ -21,14 +22,17 @@ func EditCard(c *gin.Context) {
 		return
 	}
 	var card struct {
-		// Primary Key
-		TalentID        string  `gorm:"not null" json:"talent_id"`         // Foreign Key: Links to Talent table
-		CardTitle       *string `json:"card_title"`                        // Title of the service
-		CardDescription *string `gorm:"type:text" json:"card_description"` // Detailed description
-		Suit            *string `json:"suit"`                              // Enum: "Heart", "Spade", "Diamond", "Clover"
-		Price           *int    `    json:"price"`                         // Price for the service
-		Duration        *int    `json:"duration"`                          // Duration in minutes
-		Tags            *string `json:"tags"`                              // Comma-separated tags
+		CardTitle               *string                    `json:"card_title"`                        // Title of the service
+		CardDescription         *string                    `gorm:"type:text" json:"card_description"` // Detailed description
+		Suit                    *string                    `json:"suit"`                              // Enum: "Heart", "Spade", "Diamond", "Clover"
+		Price                   *int                       `    json:"price"`                         // Price for the service
+		Duration                *int                       `json:"duration"`                          // Duration in minutes
+		Tags                    *string                    `json:"tags"`                              // Comma-separated tags
+		CancellationPolicy      *models.CancellationPolicy `json:"cancellation_policy"`               // Refund tiers; [] restores the default
+		MaxReschedules          *int                       `json:"max_reschedules"`                   // How often one booking may be moved
+		RescheduleApproval      *bool                      `json:"reschedule_approval"`               // Reschedules by the booker need approval
+		Capacity                *int                       `json:"capacity"`                          // Users per session
+		schedulingRuleOverrides                            // Per-card buffers, notice and horizon; -1 restores the talent's rule
 	}
 	fmt.Println("Binding Json")
 	// Parse the request body into the input struct
@@ -54,12 +58,11 @@ func EditCard(c *gin.Context) {
 		return
 	}
 	fmt.Println("Existing card retrieved:", existingCard)
-	//Check if talent id match with the one who created this service card
+	//Check if the authenticated user owns the talent who created this service card
 	fmt.Println("Checking for Talent authorization")
 
-	if card.TalentID != existingCard.TalentID {
+	if !authorizeTalent(c, existingCard.TalentID) {
 		fmt.Println("Error: Unauthorized to edit this card")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized to edit this card"})
 		return
 	}
 
@@ -84,6 +87,38 @@ func EditCard(c *gin.Context) {
 	if card.Tags != nil {
 		updates["tags"] = *card.Tags
 	}
+	if card.CancellationPolicy != nil {
+		if err := card.CancellationPolicy.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cancellation_policy: " + err.Error()})
+			return
+		}
+		updates["cancellation_policy"] = *card.CancellationPolicy
+	}
+	if card.MaxReschedules != nil {
+		if *card.MaxReschedules < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_reschedules must not be negative"})
+			return
+		}
+		updates["max_reschedules"] = *card.MaxReschedules
+	}
+	if card.RescheduleApproval != nil {
+		updates["reschedule_approval"] = *card.RescheduleApproval
+	}
+	if card.Capacity != nil {
+		if *card.Capacity < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "capacity must be at least 1"})
+			return
+		}
+		updates["capacity"] = *card.Capacity
+	}
+	overrides, ok := card.schedulingRuleOverrides.columns(true)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": schedulingRulesError})
+		return
+	}
+	for column, value := range overrides {
+		updates[column] = value
+	}
 
 	fmt.Println("Saving updated card to database:", updates)
 	// Save the updated card to the database
@@ -92,6 +127,8 @@ func EditCard(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update card"})
 		return
 	}
+	// Buffers of the card apply to its booked sessions
+	booking.InvalidateSnapshot(existingCard.TalentID)
 
 	var updatedCard models.ServiceCard
 	if err := config.DB.Where("card_id = ?", cardId).First(&updatedCard).Error; err != nil {
@@ -121,19 +158,6 @@ func DeleteCard(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Card ID is required"})
 		return
 	}
-	fmt.Println("Parsing JSON")
-	var card struct {
-		TalentID string `gorm:"not null" json:"talent_id"`
-	}
-
-	// Parse the request body into the input struct
-	if err := c.ShouldBindJSON(&card); err != nil {
-		fmt.Println("Error binding JSON:", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	fmt.Println("Input from the request:", card)
 	fmt.Println("Retrieving card from the database")
 
 	//Retrieve the existing card from the database
@@ -150,11 +174,10 @@ func DeleteCard(c *gin.Context) {
 		return
 	}
 	fmt.Println("Existing card from database:", existingCard)
-	//Check if talent id match with the one who created this service card
+	//Check if the authenticated user owns the talent who created this service card
 	fmt.Println("Checking Talent authorization")
-	if card.TalentID != existingCard.TalentID {
+	if !authorizeTalent(c, existingCard.TalentID) {
 		fmt.Println("Error: Unauthorized to delete this card")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized to delete this card"})
 		return
 	}
 
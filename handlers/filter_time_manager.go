@@ -1,30 +1,52 @@
 package handlers
 
 import (
-	"fmt"
 	"log"
 	"net/http"
-	"strings"
+	"sort"
 	"time"
 
+	"taas-api/booking"
 	"taas-api/config"
-	"taas-api/models"
+	"taas-api/middleware"
+	"taas-api/scheduling"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 // Response struct for frontend
 type AvailableSlotResponse struct {
-	AvailableSlots map[string][]string `json:"available_slots"`
-	BookedSlots    map[string][]string `json:"booked_slots"`
+	AvailableSlots map[string][]string       `json:"available_slots"`
+	BookedSlots    map[string][]string       `json:"booked_slots"`
+	RemainingSeats map[string]map[string]int `json:"remaining_seats,omitempty"` // Seats left per date and slot, when card_id is given
+	TimeZone       string                    `json:"time_zone"`                 // Zone the dates and slots are shown in; "" is the server's
 }
 
-// Handler to fetch all the available slots for a specific date.
+// ScheduleDay is one date of the paged filtered schedule, on the requester's calendar.
+type ScheduleDay struct {
+	Date           string         `json:"date"` // YYYY-MM-DD
+	AvailableSlots []string       `json:"available_slots"`
+	BookedSlots    []string       `json:"booked_slots"`
+	RemainingSeats map[string]int `json:"remaining_seats,omitempty"` // Seats left per slot, when card_id is given
+}
+
+// FilteredScheduleResponse is a page of the filtered schedule in date order.
+type FilteredScheduleResponse struct {
+	Data       []ScheduleDay `json:"data"`
+	NextCursor string        `json:"next_cursor"` // Pass as cursor for the following page; "" on the last one
+	TimeZone   string        `json:"time_zone"`
+}
+
+// Handler to fetch the available and booked slots of a talent, a page of dates
+// at a time in date order. from, to and cursor are dates on the requester's
+// calendar; legacy=true answers with the old AvailableSlotResponse maps of every
+// date between from and to instead.
 func FetchBookFilteredAvailableTimeSlots(c *gin.Context) {
 	db := config.DB
 	talentID := c.Query("talent_id")
 	cardDurationParam := c.Query("duration")
+	cardID := c.Query("card_id")     // Optional; reports seats left for group sessions of this card
+	timeZone := c.Query("time_zone") // Optional; defaults to the signed-in user's zone, then the talent's
 
 	log.Printf("Starting FetchFilteredAvailableTimeSlots with talentID: %s, duration: %s\n", talentID, cardDurationParam)
 
@@ -34,6 +56,11 @@ func FetchBookFilteredAvailableTimeSlots(c *gin.Context) {
 		return
 	}
 
+	page, ok := parseSchedulePage(c)
+	if !ok {
+		return
+	}
+
 	duration, err := time.ParseDuration(cardDurationParam + "m")
 	if err != nil {
 		log.Printf("Error: Invalid duration format: %v\n", err)
@@ -41,170 +68,210 @@ func FetchBookFilteredAvailableTimeSlots(c *gin.Context) {
 		return
 	}
 	log.Printf("Parsed duration: %v\n", duration)
-	// Calculate the start of today (midnight)
-	today := time.Now().Truncate(time.Hour * 24)
-
-	var availableTimeSlots []models.AvailableTimeSlots
-	log.Println("Fetching all available time slots")
-	// Fetch available slots that are today or in future
-	if err := db.Where("talent_id = ? AND available_date >= ?", talentID, today).Find(&availableTimeSlots).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			log.Println("Error: Talent is not availabe on this selected date. ")
-			c.JSON(http.StatusNotFound, gin.H{"error": "Talent is not availabe on this selected date. "})
+
+	// Slots are generated on the talent's wall clock and shown on the requester's
+	talentZone, requesterZone, err := requestZones(c, talentID, timeZone)
+	if err != nil {
+		respondZoneError(c, err)
+		return
+	}
+
+	capacity := 1
+	if cardID != "" {
+		if capacity, err = booking.CardCapacity(db, cardID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch card: " + err.Error()})
 			return
 		}
+	}
+	// Buffers space the slots apart; minimum notice and horizon hide slots that cannot be booked
+	rules, err := booking.CardRules(db, talentID, cardID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheduling rules: " + err.Error()})
+		return
+	}
+	now := time.Now()
+	// Today is the talent's calendar day, stored like all dates as midnight UTC
+	today := booking.Day(now, talentZone)
+
+	// The talent's availability over the page and the bookings on it come from a
+	// cached snapshot; holds expire on their own and are read fresh. The page's
+	// dates are on the requester's calendar, at most a day off the talent's, so
+	// the window reaches a day further on both sides. It starts today at the
+	// earliest and ends at the booking horizon at the latest; without either a to
+	// or a horizon, it is open and expands the templates from its start on.
+	windowFrom := today
+	if start := page.Start().AddDate(0, 0, -1); start.After(windowFrom) {
+		windowFrom = start
+	}
+	var windowTo time.Time
+	if !page.To.IsZero() {
+		windowTo = page.To.AddDate(0, 0, 1)
+	}
+	if rules.MaxDaysInAdvance > 0 {
+		if horizon := today.AddDate(0, 0, rules.MaxDaysInAdvance); windowTo.IsZero() || windowTo.After(horizon) {
+			windowTo = horizon
+		}
+	}
+	snapshot, err := booking.LoadSnapshot(db, talentID, windowFrom, windowTo, now)
+	if err != nil {
 		log.Printf("Error: Failed to fetch talent availability: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch talent availability. " + err.Error()})
 		return
 	}
-
-	log.Printf("Successfully fetched available time slots. Number of records: %d \n", len(availableTimeSlots))
+	held := make(map[string][]booking.Occupancy)
+	if len(snapshot.Days) > 0 {
+		heldTo := windowTo
+		if heldTo.IsZero() {
+			heldTo = snapshot.Days[len(snapshot.Days)-1].Date
+		}
+		held, err = booking.HeldOccupancy(db, talentID, windowFrom, heldTo, middleware.UserID(c), now)
+		if err != nil {
+			log.Printf("Error: Failed to fetch held slots: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch held slots: " + err.Error()})
+			return
+		}
+	}
+	log.Printf("Fetched availability of talent %s: %d days\n", talentID, len(snapshot.Days))
 
 	availableSlotsMap := make(map[string][]string)
 	bookedSlotsMap := make(map[string][]string)
-
-	for _, availableTimeSlot := range availableTimeSlots {
-		log.Printf("Processing available time slot for date: %v\n", availableTimeSlot.AvailableDate)
-
-		// Fetch booked slots for the date
-		var bookings []models.BookingRequests
-		log.Printf("Fetching bookings for talent id: %s and date: %v\n", talentID, availableTimeSlot.AvailableDate)
-		if err := db.Where("talent_id = ? AND booking_date = ?", talentID, availableTimeSlot.AvailableDate).Find(&bookings).Error; err != nil {
-			log.Printf("Error: Failed to fetch booked slots: %v\n", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch booked slots: " + err.Error()})
+	remainingSeatsMap := make(map[string]map[string]int)
+
+	// addDay lists a date the requester sees slots on. New dates of the page are
+	// pending until they become final and are counted.
+	final := 0
+	pending := make(map[time.Time]bool)
+	addDay := func(day string) {
+		if _, ok := availableSlotsMap[day]; ok {
 			return
 		}
-		log.Printf("Successfully fetched booked slots. Number of records: %d\n", len(bookings))
-		// Print all fetched booked slots
-		for _, booking := range bookings {
-			log.Printf("   Fetched Booked Slots: %+v\n", booking)
+		availableSlotsMap[day] = nil
+		bookedSlotsMap[day] = nil
+		if date, err := time.Parse("2006-01-02", day); err == nil && page.Includes(date) && !date.Before(page.Start()) {
+			pending[date] = true
 		}
+	}
 
-		// Generate all possible slots based on the first available time range. If there are multiple ranges, combine them
-		var allSlots []string
-		for _, timeRange := range availableTimeSlot.AvailableSlots {
-			startTime, _ := time.Parse("15:04", timeRange.StartTime)
-			endTime, _ := time.Parse("15:04", timeRange.EndTime)
-			allSlots = append(allSlots, generateAllTimeSlots(startTime, endTime, duration)...)
-
+	for _, available := range snapshot.Days {
+		// A talent day shows on the requester's calendar from the day before it on,
+		// so the dates before that are final. The paged response stops once they
+		// hold limit days with availability, and one more to tell whether another
+		// page follows.
+		cutoff := available.Date.AddDate(0, 0, -1)
+		for date := range pending {
+			if date.Before(cutoff) {
+				final++
+				delete(pending, date)
+			}
 		}
-		log.Printf("  Generated all possible slots: %v\n", allSlots)
-
-		// Collect all booked time ranges
-		bookedTimeRanges := make([]models.TimeRange, 0)
-		for _, booking := range bookings {
-			bookedTimeRanges = append(bookedTimeRanges, booking.BookedTime...)
+		if !page.Legacy && final > page.Limit {
+			break
 		}
-		log.Printf("   Booked time ranges from booking table: %v\n", bookedTimeRanges)
-
-		var availableSlots []string
-		var bookedSlots []string
-
-		// Compare and generate available and booked slots
-		if availableTimeSlot.AvailableDate.Equal(time.Now().Truncate(time.Hour * 24)) {
-			currentTime := time.Now()
-			for _, slot := range allSlots {
-				slotStartTime, slotEndTime, err := parseTimeRange1(slot)
-				if err != nil {
-					log.Printf("Error parsing time range: %v\n", err)
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse time range: " + err.Error()})
-					return
-				}
-				parsedSlotStartTime, err := time.Parse("15:04", slotStartTime)
-				if err != nil {
-					log.Printf("Error parsing time: %v\n", err)
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse time: " + err.Error()})
-					return
-				}
-
-				slotTime := time.Date(availableTimeSlot.AvailableDate.Year(), availableTimeSlot.AvailableDate.Month(), availableTimeSlot.AvailableDate.Day(), parsedSlotStartTime.Hour(), parsedSlotStartTime.Minute(), 0, 0, time.Local)
-				if slotTime.After(currentTime) {
-					if isTimeSlotOverlapping(slotStartTime, slotEndTime, bookedTimeRanges) {
-						bookedSlots = append(bookedSlots, slot)
-					} else {
-						availableSlots = append(availableSlots, slot)
-					}
-
-				} else {
-					if isTimeSlotOverlapping(slotStartTime, slotEndTime, bookedTimeRanges) {
-						bookedSlots = append(bookedSlots, slot)
-					}
-					// Slots in the past are skipped, they are not available now.
-				}
-
+		// Slots other users hold during checkout are taken as well, while group
+		// sessions of the card stay available until every seat is taken
+		dateKey := available.Date.Format("2006-01-02")
+		occupied := append(append([]booking.Occupancy{}, snapshot.Booked[dateKey]...), held[dateKey]...)
+		// Shows a slot of this day on the requester's wall clock, keyed by the day it starts on there
+		render := func(slot scheduling.Interval) (string, string) {
+			day, shown, err := booking.Render(available.Date, booking.RangesOf([]scheduling.Interval{slot})[0], talentZone, requesterZone)
+			if err != nil {
+				return dateKey, slot.String()
 			}
-		} else {
-			for _, slot := range allSlots {
-				slotStartTime, slotEndTime, err := parseTimeRange(slot)
-				if err != nil {
-					log.Printf("Error parsing time range: %v\n", err)
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse time range: " + err.Error()})
-					return
-				}
-				if isTimeSlotOverlapping(slotStartTime, slotEndTime, bookedTimeRanges) {
-					bookedSlots = append(bookedSlots, slot)
-				} else {
-					availableSlots = append(availableSlots, slot)
-				}
+			return day.Format("2006-01-02"), shown.StartTime + "-" + shown.EndTime
+		}
 
+		var availableSlots []booking.TiledSlot
+		var bookedSlots []booking.TiledSlot
+
+		// Tile every available range with slots of the card's duration, kept apart by
+		// the buffers. Slots within the minimum notice (including past ones) or beyond
+		// the horizon are not offered, nor are slots that a daylight saving change
+		// makes longer or shorter than the card.
+		for _, slot := range booking.TileDay(available, int(duration.Minutes()), cardID, middleware.UserID(c), capacity, rules, occupied, talentZone, now) {
+			if slot.SeatsLeft == 0 {
+				bookedSlots = append(bookedSlots, slot)
+			} else if slot.Bookable {
+				availableSlots = append(availableSlots, slot)
 			}
 		}
-		log.Printf("  Available slots after compare: %v\n", availableSlots)
-		log.Printf("  Booked slots after compare: %v\n", bookedSlots)
-
-		// Store slots in map for each date
-		availableSlotsMap[availableTimeSlot.AvailableDate.Format("2006-01-02")] = availableSlots
-		bookedSlotsMap[availableTimeSlot.AvailableDate.Format("2006-01-02")] = bookedSlots
 
-		log.Printf("Completed processing for available time slot for date: %v\n", availableTimeSlot.AvailableDate)
+		// Store slots in map for each date the requester sees them on; dates
+		// without slots are listed as well
+		for _, timeRange := range booking.SetOf(available.Ranges) {
+			day, _ := render(timeRange)
+			addDay(day)
+		}
+		for _, slot := range availableSlots {
+			day, shown := render(slot.Interval)
+			addDay(day)
+			availableSlotsMap[day] = append(availableSlotsMap[day], shown)
+			if cardID != "" {
+				if remainingSeatsMap[day] == nil {
+					remainingSeatsMap[day] = make(map[string]int)
+				}
+				remainingSeatsMap[day][shown] = slot.SeatsLeft
+			}
+		}
+		for _, slot := range bookedSlots {
+			day, shown := render(slot.Interval)
+			addDay(day)
+			bookedSlotsMap[day] = append(bookedSlotsMap[day], shown)
+		}
 
 	}
 
-	// Create response struct
-	response := AvailableSlotResponse{
-		AvailableSlots: availableSlotsMap,
-		BookedSlots:    bookedSlotsMap,
+	if page.Legacy {
+		for day := range availableSlotsMap {
+			if date, err := time.Parse("2006-01-02", day); err == nil && !page.Includes(date) {
+				delete(availableSlotsMap, day)
+				delete(bookedSlotsMap, day)
+				delete(remainingSeatsMap, day)
+			}
+		}
+		response := AvailableSlotResponse{
+			AvailableSlots: availableSlotsMap,
+			BookedSlots:    bookedSlotsMap,
+			TimeZone:       booking.ZoneName(requesterZone),
+		}
+		if cardID != "" {
+			response.RemainingSeats = remainingSeatsMap
+		}
+		c.JSON(http.StatusOK, response)
+		return
 	}
 
-	c.JSON(http.StatusOK, response)
-	log.Println("Successfully returned all available slots.")
-
-}
-
-// Helper function to generate all possible slots based on start time, end time and card duration
-func generateAllTimeSlots(startTime time.Time, endTime time.Time, duration time.Duration) []string {
-	var slots []string
-	current := startTime
-	for current.Before(endTime) {
-		next := current.Add(duration)
-		slots = append(slots, current.Format("15:04")+"-"+next.Format("15:04"))
-		current = next
+	// Order the requester's dates and cut the page out of them
+	var dates []time.Time
+	for day := range availableSlotsMap {
+		date, err := time.Parse("2006-01-02", day)
+		if err == nil && page.Includes(date) && !date.Before(page.Start()) {
+			dates = append(dates, date)
+		}
 	}
-
-	return slots
-}
-func parseTimeRange1(timeRange string) (string, string, error) {
-	parts := strings.Split(timeRange, "-")
-	if len(parts) != 2 {
-		return "", "", fmt.Errorf("invalid time range format")
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	more := len(dates) > page.Limit
+	if more {
+		dates = dates[:page.Limit]
 	}
-	return parts[0], parts[1], nil
-}
-
-func isTimeSlotOverlapping(slotStartTime string, slotEndTime string, bookedTimeRanges []models.TimeRange) bool {
 
-	parsedSlotStartTime, _ := time.Parse("15:04", slotStartTime)
-	parsedSlotEndTime, _ := time.Parse("15:04", slotEndTime)
-
-	// Iterate through all booked time ranges to check for overlap
-	for _, bookedTimeRange := range bookedTimeRanges {
-		parsedBookedStartTime, _ := time.Parse("15:04", bookedTimeRange.StartTime)
-		parsedBookedEndTime, _ := time.Parse("15:04", bookedTimeRange.EndTime)
-
-		// Check if the current slot overlaps with the booked time range
-		if !(parsedSlotEndTime.Before(parsedBookedStartTime) || parsedSlotStartTime.After(parsedBookedEndTime)) {
-			return true // Overlapping
+	response := FilteredScheduleResponse{Data: []ScheduleDay{}, TimeZone: booking.ZoneName(requesterZone)}
+	for _, date := range dates {
+		day := date.Format("2006-01-02")
+		scheduleDay := ScheduleDay{
+			Date:           day,
+			AvailableSlots: append([]string{}, availableSlotsMap[day]...),
+			BookedSlots:    append([]string{}, bookedSlotsMap[day]...),
 		}
+		if cardID != "" {
+			scheduleDay.RemainingSeats = remainingSeatsMap[day]
+		}
+		response.Data = append(response.Data, scheduleDay)
+	}
+	if len(dates) > 0 {
+		response.NextCursor = page.Cursor(dates[len(dates)-1], more)
 	}
-	return false
+
+	c.JSON(http.StatusOK, response)
+	log.Println("Successfully returned all available slots.")
+
 }
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"taas-api/booking"
+	"taas-api/config"
+	"taas-api/middleware"
+	"taas-api/models"
+	"taas-api/policy"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"storj.io/common/uuid"
+)
+
+var errHoldNotActive = errors.New("hold is not active")
+
+// CreateHoldRequest is the body of POST /api/booking-holds.
+type CreateHoldRequest struct {
+	CardID          string             `json:"card_id" binding:"required"` // The title and duration come from the card
+	TalentID        string             `json:"talent_id" binding:"required"`
+	SessionType     string             `json:"session_type" binding:"required"` // Enum: CoffeeCall, Regular
+	SpecialRequests string             `json:"special_requests,omitempty"`
+	HoldMinutes     int                `json:"hold_minutes"` // Defaults to 10, at most 30
+	Slots           []BookingSlotInput `json:"slots" binding:"required"`
+	TimeZone        string             `json:"time_zone"` // Zone of the slots; defaults to the user's
+}
+
+// CreateBookingHold handles POST /api/booking-holds. It reserves the slots for the
+// authenticated user for a few minutes so they cannot be taken during checkout.
+func CreateBookingHold(c *gin.Context) {
+	var req CreateHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	holdFor := booking.DefaultHoldDuration
+	if req.HoldMinutes != 0 {
+		holdFor = time.Duration(req.HoldMinutes) * time.Minute
+		if holdFor <= 0 || holdFor > booking.MaxHoldDuration {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("hold_minutes must be between 1 and %d", int(booking.MaxHoldDuration.Minutes()))})
+			return
+		}
+	}
+
+	talentZone, requesterZone, err := requestZones(c, req.TalentID, req.TimeZone)
+	if err != nil {
+		respondZoneError(c, err)
+		return
+	}
+	days, slots, err := parseBookingSlots(req.Slots, requesterZone, talentZone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	card, ok := bookingCard(c, req.CardID, req.TalentID)
+	if !ok {
+		return
+	}
+	if err := checkSlotLengths(req.Slots, card); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	holdID, err := uuid.New()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate hold ID"})
+		return
+	}
+	userID := middleware.UserID(c)
+	expiresAt := time.Now().Add(holdFor)
+
+	var holds []models.BookingHold
+	for _, day := range days {
+		holds = append(holds, models.BookingHold{
+			HoldID:          holdID.String(),
+			Kind:            models.HoldCheckout,
+			UserID:          userID,
+			TalentID:        req.TalentID,
+			CardID:          req.CardID,
+			CardTitle:       card.CardTitle,
+			SessionType:     models.SessionType(req.SessionType),
+			BookingDate:     day.Date,
+			HeldTime:        day.Ranges,
+			SpecialRequests: req.SpecialRequests,
+			ExpiresAt:       expiresAt,
+		})
+	}
+
+	var conflicts []booking.Conflict
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := booking.LockTalent(tx, req.TalentID); err != nil {
+			return err
+		}
+		// A new hold replaces the user's earlier checkout holds on this talent;
+		// pending reschedules and waitlist offers are kept
+		if err := booking.ActiveHolds(tx.Model(&models.BookingHold{}), time.Now()).
+			Where("user_id = ? AND talent_id = ? AND kind = ?", userID, req.TalentID, models.HoldCheckout).
+			Update("released_at", time.Now()).Error; err != nil {
+			return err
+		}
+		var err error
+		conflicts, err = booking.CheckSlots(tx, req.TalentID, req.CardID, userID, slots)
+		if err != nil || len(conflicts) > 0 {
+			return err
+		}
+		return tx.Create(&holds).Error
+	})
+	if errors.Is(err, booking.ErrTalentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Talent not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating booking hold for user %s: %v\n", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hold slots"})
+		return
+	}
+	if len(conflicts) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Some of the selected slots are no longer available", "conflicts": renderConflicts(conflicts, talentZone, requesterZone)})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Slots held",
+		"hold_id":    holdID.String(),
+		"expires_at": expiresAt,
+		"holds":      holds,
+	})
+}
+
+// ConfirmBookingHold handles POST /api/booking-holds/:id/confirm and turns an
+// active checkout hold or waitlist offer into booking requests. The hold behind a
+// pending reschedule only becomes a booking through the talent's approval.
+func ConfirmBookingHold(c *gin.Context) {
+	var input struct {
+		TimeZone string `json:"time_zone"` // Zone conflicts are shown in; defaults to the user's
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	userID := middleware.UserID(c)
+	holdID := c.Param("id")
+
+	var user models.Users_ref
+	if err := config.DB.Where("user_id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	if missing := missingBookingVerification(user); len(missing) > 0 {
+		policy.Forbid(c, "Please verify your "+strings.Join(missing, " and ")+" before booking")
+		return
+	}
+
+	var created []models.BookingRequests
+	var conflicts []booking.Conflict
+	var talentID string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var holds []models.BookingHold
+		if err := booking.ActiveHolds(tx, time.Now()).
+			Where("hold_id = ? AND user_id = ? AND kind IN ?", holdID, userID, []string{models.HoldCheckout, models.HoldWaitlist}).
+			Find(&holds).Error; err != nil {
+			return err
+		}
+		if len(holds) == 0 {
+			return errHoldNotActive
+		}
+		talentID = holds[0].TalentID
+		if err := booking.LockTalent(tx, talentID); err != nil {
+			return err
+		}
+
+		// Re-check under the lock; the user's own hold is ignored by CheckSlots
+		var slots []booking.Slot
+		for _, h := range holds {
+			for _, r := range h.HeldTime {
+				slots = append(slots, booking.Slot{Date: h.BookingDate, Range: r})
+			}
+		}
+		var err error
+		conflicts, err = booking.CheckSlots(tx, holds[0].TalentID, holds[0].CardID, userID, slots)
+		if err != nil || len(conflicts) > 0 {
+			return err
+		}
+
+		now := time.Now()
+		for _, h := range holds {
+			bookingID, err := uuid.New()
+			if err != nil {
+				return err
+			}
+			b := models.BookingRequests{
+				BookingID:       bookingID.String(),
+				CardID:          h.CardID,
+				CardTitle:       h.CardTitle,
+				UserID:          userID,
+				TalentID:        h.TalentID,
+				SessionType:     h.SessionType,
+				BookedTime:      h.HeldTime,
+				BookingDate:     h.BookingDate,
+				PaymentStatus:   models.Pending, // Only the payment flow marks a booking Paid
+				SpecialRequests: h.SpecialRequests,
+			}
+			if err := booking.Create(tx, &b, booking.Actor{ID: userID, Role: booking.ActorUser}); err != nil {
+				return err
+			}
+			if err := tx.Model(&h).Updates(map[string]interface{}{"released_at": now, "booking_id": b.BookingID}).Error; err != nil {
+				return err
+			}
+			// Holds offered from the waitlist close the user's entry
+			if err := tx.Model(&models.WaitlistEntry{}).Where("offer_hold_id = ?", h.HoldID).
+				Update("status", models.WaitlistBooked).Error; err != nil {
+				return err
+			}
+			created = append(created, b)
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errHoldNotActive):
+		c.JSON(http.StatusGone, gin.H{"error": "Hold not found or expired"})
+	case errors.Is(err, booking.ErrTalentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Talent not found"})
+	case err != nil:
+		log.Printf("Error confirming booking hold %s: %v\n", holdID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm booking"})
+	case len(conflicts) > 0:
+		talentZone, requesterZone, err := requestZones(c, talentID, input.TimeZone)
+		if err != nil {
+			respondZoneError(c, err)
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "Some of the held slots are no longer available", "conflicts": renderConflicts(conflicts, talentZone, requesterZone)})
+	default:
+		booking.InvalidateSnapshot(created[0].TalentID)
+		c.JSON(http.StatusCreated, gin.H{"message": "Bookings created successfully", "bookings": created})
+	}
+}
+
+// ReleaseBookingHold handles DELETE /api/booking-holds/:id and gives the slots of
+// a checkout hold back. Waitlist offers are declined by leaving the waitlist, and
+// reschedule holds end with the reschedule request.
+func ReleaseBookingHold(c *gin.Context) {
+	result := booking.ActiveHolds(config.DB.Model(&models.BookingHold{}), time.Now()).
+		Where("hold_id = ? AND user_id = ? AND kind = ?", c.Param("id"), middleware.UserID(c), models.HoldCheckout).
+		Update("released_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release hold"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Hold not found or already released"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Hold released"})
+}
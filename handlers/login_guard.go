@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"taas-api/config"
+	"taas-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginAccountKey normalizes the email an attempt is made for, so that case
+// variations share one counter.
+func loginAccountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// beginLoginAttempt enforces lockouts and progressive delays before credentials
+// are checked. It returns false after writing a 429 response when the IP or the
+// account is locked. Limiter failures are logged and the attempt is let through.
+func beginLoginAttempt(c *gin.Context, email string) bool {
+	decision, err := config.LoginLimiter.Check(c.Request.Context(), c.ClientIP(), loginAccountKey(email))
+	if err != nil {
+		log.Printf("Login limiter check failed: %v\n", err)
+		return true
+	}
+	if decision.Locked {
+		respondLockedOut(c, decision.RetryAfter)
+		return false
+	}
+	if decision.Delay > 0 {
+		select {
+		case <-time.After(decision.Delay):
+		case <-c.Request.Context().Done():
+			return false
+		}
+	}
+	return true
+}
+
+// loginAttemptFailed records a failed attempt and writes the 401 (or 429 when this
+// failure triggered a lockout) response.
+func loginAttemptFailed(c *gin.Context, email, userID, event, reason string) {
+	recordAuthEvent(c, email, userID, event, reason)
+
+	decision, err := config.LoginLimiter.Fail(c.Request.Context(), c.ClientIP(), loginAccountKey(email))
+	if err != nil {
+		log.Printf("Login limiter failed to record attempt: %v\n", err)
+	}
+	if decision.Locked {
+		recordAuthEvent(c, email, userID, models.AuthEventLockedOut, "too many failed attempts")
+		respondLockedOut(c, decision.RetryAfter)
+		return
+	}
+
+	message := "Invalid credentials"
+	if event == models.AuthEventMFAFailed {
+		message = "Invalid two-factor authentication code"
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": message})
+}
+
+// loginAttemptSucceeded records a completed sign-in and clears the account's failures.
+func loginAttemptSucceeded(c *gin.Context, email, userID string) {
+	recordAuthEvent(c, email, userID, models.AuthEventLoginSucceeded, "")
+	if err := config.LoginLimiter.Succeed(c.Request.Context(), loginAccountKey(email)); err != nil {
+		log.Printf("Login limiter failed to reset account: %v\n", err)
+	}
+}
+
+// recordAuthEvent writes an auth_events row. Failures are logged, never returned.
+func recordAuthEvent(c *gin.Context, email, userID, event, reason string) {
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	authEvent := models.AuthEvent{
+		UserID:    userID,
+		Email:     loginAccountKey(email),
+		IP:        c.ClientIP(),
+		UserAgent: userAgent,
+		Event:     event,
+		Reason:    reason,
+	}
+	if err := config.DB.Create(&authEvent).Error; err != nil {
+		log.Printf("Error recording auth event %s for %s: %v\n", event, authEvent.Email, err)
+	}
+}
+
+func respondLockedOut(c *gin.Context, retryAfter time.Duration) {
+	seconds := int(retryAfter.Seconds()) + 1
+	c.Header("Retry-After", fmt.Sprintf("%d", seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many failed sign-in attempts, try again later",
+		"retry_after": seconds,
+	})
+}
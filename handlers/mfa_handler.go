@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"taas-api/config"
+	"taas-api/middleware"
+	"taas-api/models"
+	"taas-api/policy"
+	"taas-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	totpIssuer        = "TaaS"
+	mfaChallengeTTL   = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+var (
+	errMFACodeInvalid = errors.New("invalid MFA code")
+	// errMFAState rejects a code sent while TOTP is not in the state the
+	// endpoint needs; it is not a guess and is not counted as one.
+	errMFAState = errors.New("two-factor authentication not in the required state")
+)
+
+// mfaChallengeResponse is returned by SignIn instead of a session when a second
+// factor is still required for the account.
+func mfaChallengeResponse(c *gin.Context, user models.Users_ref, purpose string) {
+	challenge, err := utils.GenerateChallengeToken(user.UserID, user.Email, user.AccountType, purpose, mfaChallengeTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	response := gin.H{
+		"userId":          user.UserID,
+		"challenge_token": challenge,
+		"expires_in":      int(mfaChallengeTTL.Seconds()),
+	}
+	if purpose == utils.PurposeMFAEnroll {
+		response["message"] = "Two-factor authentication must be set up before signing in"
+		response["mfa_enrollment_required"] = true
+	} else {
+		response["message"] = "Two-factor authentication code required"
+		response["mfa_required"] = true
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// mfaRequiredFor reports which challenge, if any, SignIn must issue for the user.
+func mfaRequiredFor(user models.Users_ref) (string, error) {
+	if user.TOTPEnabledAt != nil {
+		return utils.PurposeMFA, nil
+	}
+	if user.AccountType == models.AccountTypeTalent {
+		required, err := talentMFARequired(config.DB)
+		if err != nil {
+			return "", err
+		}
+		if required {
+			return utils.PurposeMFAEnroll, nil
+		}
+	}
+	return "", nil
+}
+
+// verifySecondFactor checks a TOTP code or, failing that, redeems a recovery code.
+// It must run inside a transaction that holds a lock on the user row.
+func verifySecondFactor(tx *gorm.DB, user models.Users_ref, code, recoveryCode string) error {
+	if code != "" {
+		step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now())
+		if !ok || step <= user.TOTPLastStep {
+			return errMFACodeInvalid
+		}
+		return tx.Model(&models.Users_ref{}).Where("user_id = ?", user.UserID).Update("totp_last_step", step).Error
+	}
+
+	if recoveryCode != "" {
+		result := tx.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.UserID, recoveryCodeHash(user.UserID, recoveryCode)).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errMFACodeInvalid
+		}
+		return nil
+	}
+	return errMFACodeInvalid
+}
+
+// VerifyMFASignIn handles POST /api/signin/mfa. It exchanges an MFA challenge token
+// and a TOTP or recovery code for a session.
+func VerifyMFASignIn(c *gin.Context) {
+	var input struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "challenge_token is required"})
+		return
+	}
+	if input.Code == "" && input.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code or recovery_code is required"})
+		return
+	}
+
+	claims, err := utils.ParseToken(input.ChallengeToken)
+	if err != nil || claims.Purpose != utils.PurposeMFA {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge token"})
+		return
+	}
+	// Second-factor guesses count against the same limits as passwords
+	if !beginLoginAttempt(c, claims.Email) {
+		return
+	}
+
+	var user models.Users_ref
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", claims.UserID).First(&user).Error; err != nil {
+			return err
+		}
+		if user.TOTPEnabledAt == nil {
+			return errMFACodeInvalid
+		}
+		return verifySecondFactor(tx, user, input.Code, input.RecoveryCode)
+	})
+	if err != nil {
+		if errors.Is(err, errMFACodeInvalid) || errors.Is(err, gorm.ErrRecordNotFound) {
+			loginAttemptFailed(c, claims.Email, claims.UserID, models.AuthEventMFAFailed, "invalid second factor")
+			return
+		}
+		log.Printf("Error verifying MFA for user %s: %v\n", claims.UserID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+
+	tokens, err := issueSession(config.DB, user, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	loginAttemptSucceeded(c, user.Email, user.UserID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Login successful",
+		"userId":        user.UserID,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+	})
+}
+
+// EnrollTOTP handles POST /api/mfa/totp/enroll. It stores a new pending secret and
+// returns the otpauth URI to render as a QR code. Accepts an access token or an
+// enrollment challenge token from SignIn.
+func EnrollTOTP(c *gin.Context) {
+	var user models.Users_ref
+	if err := config.DB.Where("user_id = ?", middleware.UserID(c)).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.TOTPEnabledAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	if err := config.DB.Model(&user).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Scan the QR code with your authenticator app, then confirm with a code",
+		"secret":      secret,
+		"otpauth_uri": utils.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+// ConfirmTOTP handles POST /api/mfa/totp/confirm. A valid code activates TOTP and
+// returns freshly generated recovery codes. When called with an enrollment
+// challenge token the sign-in is completed as well.
+func ConfirmTOTP(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+	email, ok := beginSecondFactorAttempt(c)
+	if !ok {
+		return
+	}
+
+	var user models.Users_ref
+	var codes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", middleware.UserID(c)).First(&user).Error; err != nil {
+			return err
+		}
+		if user.TOTPEnabledAt != nil || user.TOTPSecret == "" {
+			return errMFAState
+		}
+		if err := verifySecondFactor(tx, user, input.Code, ""); err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := tx.Model(&models.Users_ref{}).Where("user_id = ?", user.UserID).Update("totp_enabled_at", now).Error; err != nil {
+			return err
+		}
+		user.TOTPEnabledAt = &now
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.UserID)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, errMFACodeInvalid) {
+			loginAttemptFailed(c, email, middleware.UserID(c), models.AuthEventMFAFailed, "invalid code on TOTP confirmation")
+			return
+		}
+		if errors.Is(err, errMFAState) || errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No pending two-factor enrollment"})
+			return
+		}
+		log.Printf("Error confirming TOTP for user %s: %v\n", middleware.UserID(c), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	response := gin.H{
+		"message":        "Two-factor authentication enabled. Store the recovery codes in a safe place.",
+		"recovery_codes": codes,
+	}
+	if middleware.Purpose(c) == utils.PurposeMFAEnroll {
+		tokens, err := issueSession(config.DB, user, "")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		loginAttemptSucceeded(c, user.Email, user.UserID)
+		response["userId"] = user.UserID
+		response["token"] = tokens.AccessToken
+		response["refresh_token"] = tokens.RefreshToken
+		response["expires_in"] = tokens.ExpiresIn
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DisableTOTP handles POST /api/mfa/totp/disable. A current TOTP or recovery code is
+// required, and talents cannot opt out while an admin has made TOTP mandatory.
+func DisableTOTP(c *gin.Context) {
+	var input struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || (input.Code == "" && input.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code or recovery_code is required"})
+		return
+	}
+
+	if middleware.AccountType(c) == models.AccountTypeTalent {
+		required, err := talentMFARequired(config.DB)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read MFA settings"})
+			return
+		}
+		if required {
+			policy.Forbid(c, "Two-factor authentication is mandatory for talent accounts")
+			return
+		}
+	}
+	email, ok := beginSecondFactorAttempt(c)
+	if !ok {
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.Users_ref
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", middleware.UserID(c)).First(&user).Error; err != nil {
+			return err
+		}
+		if user.TOTPEnabledAt == nil {
+			return errMFAState
+		}
+		if err := verifySecondFactor(tx, user, input.Code, input.RecoveryCode); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Users_ref{}).Where("user_id = ?", user.UserID).Updates(map[string]interface{}{
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.UserID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		if errors.Is(err, errMFACodeInvalid) {
+			loginAttemptFailed(c, email, middleware.UserID(c), models.AuthEventMFAFailed, "invalid code on TOTP disable")
+			return
+		}
+		if errors.Is(err, errMFAState) || errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+			return
+		}
+		log.Printf("Error disabling TOTP for user %s: %v\n", middleware.UserID(c), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// beginSecondFactorAttempt applies the sign-in limits to a code sent by a
+// signed-in user, so that codes cannot be guessed outside of sign-in either. It
+// returns the account's email, the limiter key, or false after answering the
+// request itself.
+func beginSecondFactorAttempt(c *gin.Context) (string, bool) {
+	var user models.Users_ref
+	if err := config.DB.Select("email").Where("user_id = ?", middleware.UserID(c)).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return "", false
+	}
+	return user.Email, beginLoginAttempt(c, user.Email)
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and creates a new set,
+// returning the raw codes. They are only ever shown once.
+func replaceRecoveryCodes(tx *gorm.DB, userID string) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := utils.GenerateNumericCode(10)
+		if err != nil {
+			return nil, err
+		}
+		code := raw[:5] + "-" + raw[5:]
+		codes = append(codes, code)
+		records = append(records, models.RecoveryCode{UserID: userID, CodeHash: recoveryCodeHash(userID, code)})
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// recoveryCodeHash normalizes and hashes a recovery code for storage and lookup.
+func recoveryCodeHash(userID, code string) string {
+	normalized := strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	return utils.HashToken(userID + ":" + normalized)
+}
+
+// talentMFARequired reports whether an admin has made TOTP mandatory for talents.
+func talentMFARequired(db *gorm.DB) (bool, error) {
+	var setting models.AppSetting
+	if err := db.Where("key = ?", models.SettingRequireTalentMFA).First(&setting).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return setting.Value == "true", nil
+}
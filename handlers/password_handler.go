@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"taas-api/config"
+	"taas-api/mailer"
+	"taas-api/models"
+	"taas-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// passwordResetTTL is how long an emailed reset link stays valid.
+	passwordResetTTL = time.Hour
+	// minPasswordLength is the shortest password accepted on reset.
+	minPasswordLength = 8
+)
+
+var errResetTokenInvalid = errors.New("invalid reset token")
+
+// ForgotPassword handles POST /api/password/forgot. It always answers with the same
+// message, and in the same time, so the endpoint cannot be used to find out which
+// emails are registered: the reset link is created and mailed in the background.
+// Requests are throttled per IP and per email.
+func ForgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
+		return
+	}
+
+	email := loginAccountKey(input.Email)
+	decision, err := config.PasswordResetLimiter.Check(c.Request.Context(), c.ClientIP(), email)
+	if err != nil {
+		log.Printf("Password reset limiter check failed: %v\n", err)
+	} else if decision.Locked {
+		seconds := int(decision.RetryAfter.Seconds()) + 1
+		c.Header("Retry-After", fmt.Sprintf("%d", seconds))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       "Too many password reset requests, try again later",
+			"retry_after": seconds,
+		})
+		return
+	}
+	if _, err := config.PasswordResetLimiter.Fail(c.Request.Context(), c.ClientIP(), email); err != nil {
+		log.Printf("Password reset limiter failed to record request: %v\n", err)
+	}
+
+	go sendPasswordReset(input.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a password reset link has been sent"})
+}
+
+// sendPasswordReset creates a reset token for the account registered with email,
+// if any, and mails the link. Failures are logged.
+func sendPasswordReset(email string) {
+	var user models.Users_ref
+	if err := config.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error looking up user for password reset: %v\n", err)
+		}
+		return
+	}
+
+	rawToken, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		log.Printf("Error creating password reset token for user %s: %v\n", user.UserID, err)
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Only the most recent reset link may be used
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.UserID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    user.UserID,
+			TokenHash: utils.HashToken(rawToken),
+			ExpiresAt: time.Now().Add(passwordResetTTL),
+		}).Error
+	})
+	if err != nil {
+		log.Printf("Error saving password reset token for user %s: %v\n", user.UserID, err)
+		return
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("We received a request to reset your password.\n\n"+
+			"Open the link below within %d minutes to choose a new password:\n%s\n\n"+
+			"If you did not request this, you can ignore this email.\n",
+			int(passwordResetTTL.Minutes()), passwordResetLink(rawToken)),
+	}
+	if err := config.Mailer.Send(context.Background(), msg); err != nil {
+		log.Printf("Error sending password reset email to user %s: %v\n", user.UserID, err)
+	}
+}
+
+// ResetPassword handles POST /api/password/reset. The token is redeemed at most once,
+// and every existing session of the account is revoked.
+func ResetPassword(c *gin.Context) {
+	var input struct {
+		Token       string `json:"token" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token and new_password are required"})
+		return
+	}
+	if len(input.NewPassword) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Password must be at least %d characters", minPasswordLength)})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var token models.PasswordResetToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", utils.HashToken(input.Token)).
+			First(&token).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errResetTokenInvalid
+			}
+			return err
+		}
+		if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+			return errResetTokenInvalid
+		}
+
+		now := time.Now()
+		if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Users_ref{}).Where("user_id = ?", token.UserID).
+			Update("password", string(hashedPassword)).Error; err != nil {
+			return err
+		}
+		// A password reset signs the account out everywhere
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", token.UserID).
+			Update("revoked_at", now).Error
+	})
+	if err != nil {
+		if errors.Is(err, errResetTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+			return
+		}
+		log.Printf("Error resetting password: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset successfully"})
+}
+
+// passwordResetLink builds the frontend URL that carries the reset token.
+func passwordResetLink(token string) string {
+	return appBaseURL() + "/reset-password?token=" + url.QueryEscape(token)
+}
+
+// appBaseURL is the public URL of the frontend, used to build links in emails.
+func appBaseURL() string {
+	if base := os.Getenv("APP_BASE_URL"); base != "" {
+		return base
+	}
+	return "http://localhost:3000"
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"taas-api/config"
+	"taas-api/middleware"
+	"taas-api/models"
+	"taas-api/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authorizeTalent checks that the authenticated caller may act for talentID and
+// writes the error response when it may not.
+func authorizeTalent(c *gin.Context, talentID string) bool {
+	return policy.AuthorizeTalent(c, config.DB, middleware.UserID(c), middleware.AccountType(c), talentID)
+}
+
+// authorizeUser checks that the authenticated caller is userID or an admin and
+// writes the error response when not.
+func authorizeUser(c *gin.Context, userID string) bool {
+	if userID == middleware.UserID(c) || middleware.AccountType(c) == models.AccountTypeAdmin {
+		return true
+	}
+	policy.Forbid(c, "You can only access your own data")
+	return false
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"taas-api/booking"
+	"taas-api/config"
+	"taas-api/middleware"
+	"taas-api/models"
+	"taas-api/policy"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RescheduleBooking handles POST /api/bookings/:id/reschedule with a new
+// booking_date and time_slots. The booking keeps its ID; only its time moves.
+// On cards that require approval, moves by the booker wait for the talent. With
+// ?scope=future all later occurrences of the series move by the same offset,
+// unless the move would need approval.
+func RescheduleBooking(c *gin.Context) {
+	var input struct {
+		BookingSlotInput
+		Reason   string `json:"reason"`
+		TimeZone string `json:"time_zone"` // Zone of booking_date and time_slots; defaults to the user's
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, actor, ok := bookingActorFor(c, func(role string) bool { return true })
+	if !ok {
+		return
+	}
+	talentZone, requesterZone, err := requestZones(c, existing.TalentID, input.TimeZone)
+	if err != nil {
+		respondZoneError(c, err)
+		return
+	}
+	days, _, err := parseBookingSlots([]BookingSlotInput{input.BookingSlotInput}, requesterZone, talentZone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(days) > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The new time must fall on one day in the talent's time zone"})
+		return
+	}
+	scope, ok := seriesScope(c, existing)
+	if !ok {
+		return
+	}
+	if scope == seriesScopeFuture {
+		rescheduleFutureOccurrences(c, existing, input.BookingSlotInput, days[0].Date, actor, input.Reason, talentZone, requesterZone)
+		return
+	}
+
+	var outcome booking.RescheduleOutcome
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		outcome, err = booking.Reschedule(tx, existing.BookingID, days[0].Date, days[0].Ranges, actor, input.Reason, time.Now())
+		return err
+	})
+	if err != nil {
+		respondRescheduleError(c, err)
+		return
+	}
+	booking.InvalidateSnapshot(existing.TalentID)
+	switch {
+	case len(outcome.Conflicts) > 0:
+		c.JSON(http.StatusConflict, gin.H{"error": "The new time is not available", "conflicts": renderConflicts(outcome.Conflicts, talentZone, requesterZone)})
+	case outcome.Pending != nil:
+		c.JSON(http.StatusAccepted, gin.H{"message": "Reschedule requested; waiting for the talent's approval", "reschedule_request": outcome.Pending})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Booking rescheduled", "booking": outcome.Booking})
+	}
+}
+
+// ApproveReschedule handles POST /api/bookings/:id/reschedule/approve.
+func ApproveReschedule(c *gin.Context) {
+	decideReschedule(c, true)
+}
+
+// RejectReschedule handles POST /api/bookings/:id/reschedule/reject.
+func RejectReschedule(c *gin.Context) {
+	decideReschedule(c, false)
+}
+
+func decideReschedule(c *gin.Context, approve bool) {
+	existing, actor, ok := bookingActorFor(c, func(role string) bool {
+		return role == booking.ActorTalent || role == booking.ActorAdmin
+	})
+	if !ok {
+		return
+	}
+
+	var outcome booking.RescheduleOutcome
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		outcome, err = booking.DecideReschedule(tx, existing.BookingID, approve, actor, time.Now())
+		if errors.Is(err, booking.ErrRescheduleExpired) {
+			// Keep the request marked as expired
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		respondRescheduleError(c, err)
+		return
+	}
+	booking.InvalidateSnapshot(existing.TalentID)
+	switch {
+	case outcome.Pending != nil && outcome.Pending.Status == models.RescheduleExpired:
+		c.JSON(http.StatusGone, gin.H{"error": "The reschedule request expired", "reschedule_request": outcome.Pending})
+	case len(outcome.Conflicts) > 0:
+		c.JSON(http.StatusConflict, gin.H{"error": "The new time is not available any more", "conflicts": outcome.Conflicts})
+	case !approve:
+		c.JSON(http.StatusOK, gin.H{"message": "Reschedule rejected", "reschedule_request": outcome.Pending})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Booking rescheduled", "booking": outcome.Booking, "reschedule_request": outcome.Pending})
+	}
+}
+
+// bookingActorFor loads the booking of the :id parameter and picks the caller's
+// first role accepted by allowed. On failure the response is written.
+func bookingActorFor(c *gin.Context, allowed func(role string) bool) (models.BookingRequests, booking.Actor, bool) {
+	var existing models.BookingRequests
+	if err := config.DB.Where("booking_id = ?", c.Param("id")).First(&existing).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return existing, booking.Actor{}, false
+	}
+	roles, err := bookingActorRoles(c, existing)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load booking"})
+		return existing, booking.Actor{}, false
+	}
+	for _, role := range roles {
+		if allowed(role) {
+			return existing, booking.Actor{ID: middleware.UserID(c), Role: role}, true
+		}
+	}
+	policy.Forbid(c, "You are not allowed to change this booking")
+	return existing, booking.Actor{}, false
+}
+
+func respondRescheduleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, booking.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+	case errors.Is(err, booking.ErrNoPendingReschedule):
+		c.JSON(http.StatusNotFound, gin.H{"error": "There is no pending reschedule request for this booking"})
+	case errors.Is(err, booking.ErrNotReschedulable):
+		c.JSON(http.StatusConflict, gin.H{"error": "This booking can no longer be rescheduled"})
+	case errors.Is(err, booking.ErrRescheduleLimit):
+		c.JSON(http.StatusConflict, gin.H{"error": "This booking has reached the reschedule limit of its card"})
+	case errors.Is(err, booking.ErrSessionMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, booking.ErrTalentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Talent not found"})
+	default:
+		log.Printf("Error rescheduling booking: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reschedule booking"})
+	}
+}
@@ -1,11 +1,14 @@
 package handlers
 
 import (
-	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"taas-api/booking"
 	"taas-api/config"
+	"taas-api/middleware"
 	"taas-api/models"
+	"taas-api/scheduling"
 
 	"time"
 
@@ -13,6 +16,10 @@ import (
 	"gorm.io/gorm"
 )
 
+// errAvailabilityBooked rolls back an availability edit that would take away
+// booked time.
+var errAvailabilityBooked = errors.New("availability edit removes booked time")
+
 // BookingRequest struct to handle data from frontend
 type BookingRequest struct {
 	TalentID  string    `json:"talent_id" binding:"required"`
@@ -25,9 +32,12 @@ type AvailabilityRequest struct {
 	TalentID       string            `json:"talent_id" binding:"required"`
 	AvailableDate  string            `json:"available_date" binding:"required"`
 	AvailableSlots models.TimeRanges `json:"available_slots" binding:"required"` // Using TimeRanges type
+	TimeZone       string            `json:"time_zone"`                          // Zone of the date and slots; defaults to the user's, then the talent's
 }
 
-// Handler to create or update available time slots
+// Handler to create or update available time slots. Slots are stored on the
+// talent's wall clock, so slots given in another zone may land on a neighbouring
+// day or be split at midnight; every touched day is returned in "records".
 func CreateAvailableSlots(c *gin.Context) {
 	log.Println("Starting CreateAvailableSlots handler")
 
@@ -41,6 +51,12 @@ func CreateAvailableSlots(c *gin.Context) {
 	}
 	log.Printf("Parsed request: %+v\n", req)
 
+	// Only the owner of the talent account may publish its availability
+	if !authorizeTalent(c, req.TalentID) {
+		log.Printf("User %s may not manage availability for talent %s\n", middleware.UserID(c), req.TalentID)
+		return
+	}
+
 	// Parse the date
 	availableDate, err := time.Parse("2006-01-02", req.AvailableDate)
 	if err != nil {
@@ -50,100 +66,285 @@ func CreateAvailableSlots(c *gin.Context) {
 	}
 	log.Printf("Parsed date: %s\n", availableDate.Format("2006-01-02"))
 
-	// Check if an entry for this talent and date already exists
-	var existingRecord models.AvailableTimeSlots
-	log.Printf("Querying database for talent_id: %s, date: %s\n", req.TalentID, availableDate)
-	err = config.DB.Where("talent_id = ? AND available_date = ?", req.TalentID, availableDate).
-		First(&existingRecord).Error
-
-	if err != nil && err != gorm.ErrRecordNotFound {
-		log.Printf("Database query error for talent_id: %s, date: %s, Error: %v\n", req.TalentID, availableDate, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query database."})
-		return
-	}
-	if err == nil {
-		// Record exists, update the slots
-		log.Printf("Existing record found: %+v\n", existingRecord)
-		//Check for booking collisions.
-		log.Println("Checking for booking collisions")
-		if !areSlotsAvailable(config.DB, req.TalentID, availableDate, req.AvailableSlots) {
-			log.Println("Booking collision detected, cannot save.")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Some of the available time slots you are trying to add are already booked, try again with different time."})
-			return
-		}
-
-		updatedSlots := append(existingRecord.AvailableSlots, req.AvailableSlots...)
-		log.Printf("Updated slots: %v\n", updatedSlots)
+	talentZone, requesterZone, err := requestZones(c, req.TalentID, req.TimeZone)
+	if err != nil {
+		respondZoneError(c, err)
+		return
+	}
+	if len(req.AvailableSlots) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one time slot is required"})
+		return
+	}
+	// Move the slots onto the talent's wall clock; they are merged into what the
+	// touched days already have
+	slots, ok := talentSlots(c, availableDate, req.AvailableSlots, requesterZone, talentZone)
+	if !ok {
+		return
+	}
+	edit, ok := applyAvailabilityEdit(c, req.TalentID, nil, slots, talentZone, requesterZone)
+	if !ok {
+		return
+	}
+	if len(edit.Records) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one time slot is required"})
+		return
+	}
 
-		existingRecord.AvailableSlots = updatedSlots
-		log.Println("Updating availability record in database")
-		if err := config.DB.Save(&existingRecord).Error; err != nil {
-			log.Printf("Error updating availability: %v\n", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update availability."})
-			return
-		}
-		log.Printf("Availability updated successfully: %+v\n", existingRecord)
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Availability updated successfully",
-			"data":    existingRecord,
+	if edit.Created {
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Availability created successfully",
+			"data":    edit.Records[0],
+			"records": edit.Records,
 		})
-		log.Println("Successfully updated and returning result")
+		log.Println("Successfully created and returning result")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Availability updated successfully",
+		"data":    edit.Records[0],
+		"records": edit.Records,
+	})
+	log.Println("Successfully updated and returning result")
+}
+
+// UpdateAvailabilityRequest edits one range of a date, or replaces all of them.
+type UpdateAvailabilityRequest struct {
+	TalentID       string            `json:"talent_id" binding:"required"`
+	AvailableDate  string            `json:"available_date" binding:"required"`
+	Slot           *models.TimeRange `json:"slot"`            // Range to change into new_slot; may be part of a stored range
+	NewSlot        *models.TimeRange `json:"new_slot"`        // Replacement of slot
+	AvailableSlots models.TimeRanges `json:"available_slots"` // Replaces every range of the date when slot is not given
+	TimeZone       string            `json:"time_zone"`       // Zone of the date and slots; defaults to the user's, then the talent's
+}
+
+// UpdateAvailableSlots handles PATCH /api/update-schedule. Either slot is moved
+// to new_slot, or the whole date is replaced by available_slots. The change is
+// refused with the bookings concerned when it takes away booked time.
+func UpdateAvailableSlots(c *gin.Context) {
+	var req UpdateAvailabilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+	if !authorizeTalent(c, req.TalentID) {
+		return
+	}
+	availableDate, err := time.Parse("2006-01-02", req.AvailableDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+		return
+	}
+	talentZone, requesterZone, err := requestZones(c, req.TalentID, req.TimeZone)
+	if err != nil {
+		respondZoneError(c, err)
 		return
 	}
-	// Record doesn't exist, create a new entry
-	log.Println("No existing record found")
-	if !areSlotsAvailable(config.DB, req.TalentID, availableDate, req.AvailableSlots) {
-		log.Println("Booking collision detected, cannot save.")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Some of the available time slots you are trying to add are already booked, try again with different time."})
+
+	var removed, added models.TimeRanges
+	switch {
+	case req.Slot != nil && req.NewSlot != nil:
+		removed, added = models.TimeRanges{*req.Slot}, models.TimeRanges{*req.NewSlot}
+	case req.Slot != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "new_slot is required with slot; use delete-schedule to remove a range"})
+		return
+	case len(req.AvailableSlots) > 0:
+		removed, added = models.TimeRanges{{StartTime: "00:00", EndTime: "24:00"}}, req.AvailableSlots
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either slot and new_slot or available_slots is required"})
 		return
 	}
-	newRecord := models.AvailableTimeSlots{
-		TalentID:       req.TalentID,
-		AvailableDate:  availableDate,
-		AvailableSlots: req.AvailableSlots,
+	remove, ok := talentSlots(c, availableDate, removed, requesterZone, talentZone)
+	if !ok {
+		return
+	}
+	add, ok := talentSlots(c, availableDate, added, requesterZone, talentZone)
+	if !ok {
+		return
 	}
-	log.Printf("Creating new availability record: %+v\n", newRecord)
-	if err := config.DB.Create(&newRecord).Error; err != nil {
-		log.Printf("Error creating availability record: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create availability record."})
+
+	edit, ok := applyAvailabilityEdit(c, req.TalentID, remove, add, talentZone, requesterZone)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Availability updated successfully", "records": edit.Records})
+}
+
+// DeleteAvailableSlot handles DELETE /api/delete-schedule with talent_id,
+// available_date and optionally time_zone as query parameters. With start_time
+// and end_time only that time is removed, otherwise the whole date. Removing
+// booked time is refused.
+func DeleteAvailableSlot(c *gin.Context) {
+	talentID := c.Query("talent_id")
+	if talentID == "" || c.Query("available_date") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "talent_id and available_date are required"})
 		return
 	}
-	log.Printf("Availability created successfully: %+v\n", newRecord)
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Availability created successfully",
-		"data":    newRecord,
+	if !authorizeTalent(c, talentID) {
+		return
+	}
+	availableDate, err := time.Parse("2006-01-02", c.Query("available_date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+		return
+	}
+	talentZone, requesterZone, err := requestZones(c, talentID, c.Query("time_zone"))
+	if err != nil {
+		respondZoneError(c, err)
+		return
+	}
+
+	removed := models.TimeRange{StartTime: "00:00", EndTime: "24:00"}
+	startTime, endTime := c.Query("start_time"), c.Query("end_time")
+	if startTime != "" || endTime != "" {
+		if startTime == "" || endTime == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_time and end_time must be given together"})
+			return
+		}
+		removed = models.TimeRange{StartTime: startTime, EndTime: endTime}
+	}
+	remove, ok := talentSlots(c, availableDate, models.TimeRanges{removed}, requesterZone, talentZone)
+	if !ok {
+		return
+	}
+
+	edit, ok := applyAvailabilityEdit(c, talentID, remove, nil, talentZone, requesterZone)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Availability deleted successfully", "records": edit.Records})
+}
+
+// talentSlots validates ranges of a date in the requester's zone and moves them
+// onto the talent's wall clock, splitting those that cross midnight there. It
+// answers the request itself when a range is invalid.
+func talentSlots(c *gin.Context, date time.Time, ranges models.TimeRanges, requesterZone, talentZone *time.Location) ([]booking.Slot, bool) {
+	var slots []booking.Slot
+	for _, r := range ranges {
+		if _, err := scheduling.Parse(r.StartTime, r.EndTime); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time slot " + r.StartTime + "-" + r.EndTime + ": " + err.Error()})
+			return nil, false
+		}
+		pieces, err := booking.ConvertSplit(date, r, requesterZone, talentZone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time slot: " + err.Error()})
+			return nil, false
+		}
+		slots = append(slots, pieces...)
+	}
+	return slots, true
+}
+
+// applyAvailabilityEdit runs booking.EditAvailability in a transaction and offers
+// added time to the waitlist once committed. It answers the request itself when
+// the edit fails or would take away booked time.
+func applyAvailabilityEdit(c *gin.Context, talentID string, remove, add []booking.Slot, talentZone, requesterZone *time.Location) (booking.AvailabilityEdit, bool) {
+	var edit booking.AvailabilityEdit
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		edit, err = booking.EditAvailability(tx, talentID, remove, add, booking.ZoneName(talentZone))
+		if err == nil && len(edit.Conflicts) > 0 {
+			return errAvailabilityBooked
+		}
+		return err
 	})
-	log.Println("Successfully created and returning result")
+	switch {
+	case errors.Is(err, errAvailabilityBooked):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "Some of the time you are removing is already booked; reschedule or cancel those bookings first",
+			"conflicts": renderConflicts(edit.Conflicts, talentZone, requesterZone),
+		})
+		return edit, false
+	case errors.Is(err, booking.ErrTalentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Talent not found"})
+		return edit, false
+	case err != nil:
+		log.Printf("Error editing availability of talent %s: %v\n", talentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save availability."})
+		return edit, false
+	}
+
+	booking.InvalidateSnapshot(talentID)
+	for _, s := range add {
+		offerFreedTime(talentID, s.Date, models.TimeRanges{s.Range})
+	}
+	return edit, true
 }
 
+// FetchrawAllAvailableTimeSlots lists a talent's one-off availability rows in
+// date order, a page of dates at a time, from today on the talent's calendar
+// unless from says otherwise. With legacy=true it answers in the old shape, all
+// rows matching from and to at once.
 func FetchrawAllAvailableTimeSlots(c *gin.Context) {
-	// Assuming you pass DB as middleware.
 	talentID := c.Query("talent_id")
 
 	if talentID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Talent ID is required"})
 		return
 	}
+	page, ok := parseSchedulePage(c)
+	if !ok {
+		return
+	}
 
-	var availableTimeSlots []models.AvailableTimeSlots
-	log.Println("Fetching availabilities for talent id : ", talentID)
-	if err := config.DB.Where("talent_id = ?", talentID).Find(&availableTimeSlots).Error; err != nil {
-		log.Printf("Database error while fetching talent availability: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch talent availabilities: " + err.Error()})
+	query := config.DB.Where("talent_id = ?", talentID)
+	if page.Legacy {
+		if !page.From.IsZero() {
+			query = query.Where("available_date >= ?", page.From)
+		}
+		if !page.To.IsZero() {
+			query = query.Where("available_date <= ?", page.To)
+		}
+		var availableTimeSlots []models.AvailableTimeSlots
+		if err := query.Order("available_date, id").Find(&availableTimeSlots).Error; err != nil {
+			log.Printf("Database error while fetching talent availability: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch talent availabilities: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"available_slots": availableTimeSlots})
 		return
 	}
-	log.Printf("Successfully fetched availabilities for talent_id: %s,  records count: %d\n", talentID, len(availableTimeSlots))
-	response := gin.H{"available_slots": availableTimeSlots}
 
-	responseBytes, err := json.MarshalIndent(response, "", "    ")
-	if err != nil {
-		log.Printf("Error while marshaling response: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to format response data"})
+	if page.From.IsZero() {
+		talentZone, err := booking.TalentZone(config.DB, talentID)
+		if err != nil {
+			respondZoneError(c, err)
+			return
+		}
+		page.From = booking.Day(time.Now(), talentZone)
+	}
+	query = query.Where("available_date >= ?", page.Start())
+	if !page.To.IsZero() {
+		query = query.Where("available_date <= ?", page.To)
+	}
+
+	// A page is a number of dates, so the rows of one date are never split
+	var dates []time.Time
+	if err := query.Model(&models.AvailableTimeSlots{}).
+		Distinct("available_date").Order("available_date").Limit(page.Limit+1).
+		Pluck("available_date", &dates).Error; err != nil {
+		log.Printf("Database error while fetching talent availability dates: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch talent availabilities: " + err.Error()})
 		return
 	}
-	log.Println("Response data:")
-	log.Println(string(responseBytes))
+	more := len(dates) > page.Limit
+	if more {
+		dates = dates[:page.Limit]
+	}
 
-	c.JSON(http.StatusOK, response)
+	availableTimeSlots := []models.AvailableTimeSlots{}
+	if len(dates) > 0 {
+		if err := config.DB.Where("talent_id = ? AND available_date IN ?", talentID, dates).
+			Order("available_date, id").Find(&availableTimeSlots).Error; err != nil {
+			log.Printf("Database error while fetching talent availability: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch talent availabilities: " + err.Error()})
+			return
+		}
+	}
+	log.Printf("Successfully fetched availabilities for talent_id: %s,  records count: %d\n", talentID, len(availableTimeSlots))
 
+	var last time.Time
+	if len(dates) > 0 {
+		last = dates[len(dates)-1]
+	}
+	c.JSON(http.StatusOK, gin.H{"talent_id": talentID, "data": availableTimeSlots, "next_cursor": page.Cursor(last, more)})
 }
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Schedule endpoints page by calendar day: a page holds up to limit dates, and
+// next_cursor is the last of them, to be passed back as cursor for the dates
+// after it.
+const (
+	defaultSchedulePageDays = 31
+	maxSchedulePageDays     = 90
+)
+
+// schedulePage is the from, to, cursor, limit and legacy query parameters of a
+// schedule endpoint. Dates are midnight UTC; a zero date is no bound.
+type schedulePage struct {
+	From   time.Time
+	To     time.Time
+	After  time.Time // Only dates after the cursor are returned
+	Limit  int       // Dates per page
+	Legacy bool      // Answer in the old unpaged map shape
+}
+
+// parseSchedulePage reads the paging parameters, answering the request itself
+// when one is invalid.
+func parseSchedulePage(c *gin.Context) (schedulePage, bool) {
+	page := schedulePage{Limit: defaultSchedulePageDays}
+	for param, target := range map[string]*time.Time{"from": &page.From, "to": &page.To, "cursor": &page.After} {
+		if value := c.Query(param); value != "" {
+			date, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + " date. Use YYYY-MM-DD."})
+				return page, false
+			}
+			*target = date
+		}
+	}
+	if !page.From.IsZero() && !page.To.IsZero() && page.To.Before(page.From) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be on or after from"})
+		return page, false
+	}
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxSchedulePageDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be 1 to " + strconv.Itoa(maxSchedulePageDays) + " days"})
+			return page, false
+		}
+		page.Limit = n
+	}
+	if value := c.Query("legacy"); value != "" {
+		legacy, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid legacy flag"})
+			return page, false
+		}
+		page.Legacy = legacy
+	}
+	return page, true
+}
+
+// Start is the first date the page may hold: the day after the cursor or from,
+// whichever is later.
+func (p schedulePage) Start() time.Time {
+	if !p.After.IsZero() {
+		if next := p.After.AddDate(0, 0, 1); next.After(p.From) {
+			return next
+		}
+	}
+	return p.From
+}
+
+// Includes reports whether a date lies within from and to; the cursor is not
+// considered, so legacy responses can use it too.
+func (p schedulePage) Includes(date time.Time) bool {
+	return !date.Before(p.From) && (p.To.IsZero() || !date.After(p.To))
+}
+
+// Cursor formats the date a following page starts after, or "" on the last page.
+func (p schedulePage) Cursor(last time.Time, more bool) string {
+	if !more {
+		return ""
+	}
+	return last.Format("2006-01-02")
+}
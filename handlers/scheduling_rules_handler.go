@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"taas-api/booking"
+	"taas-api/config"
+	"taas-api/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// schedulingRuleOverrides are the per-card scheduling rules accepted when creating
+// or editing a card. Unset fields follow the talent's rules; on edit, -1 removes
+// an override again.
+type schedulingRuleOverrides struct {
+	BufferBeforeMinutes *int `json:"buffer_before_minutes"`
+	BufferAfterMinutes  *int `json:"buffer_after_minutes"`
+	MinNoticeMinutes    *int `json:"min_notice_minutes"`
+	MaxDaysInAdvance    *int `json:"max_days_in_advance"`
+}
+
+// columns maps the overrides that are set to their ServiceCard columns, with -1
+// becoming NULL when allowClear is set. It returns false if a value is out of range.
+func (o schedulingRuleOverrides) columns(allowClear bool) (map[string]interface{}, bool) {
+	columns := make(map[string]interface{})
+	set := func(column string, value *int, max int) bool {
+		switch {
+		case value == nil:
+		case allowClear && *value == -1:
+			columns[column] = nil
+		case *value < 0 || *value > max:
+			return false
+		default:
+			columns[column] = *value
+		}
+		return true
+	}
+	ok := set("buffer_before_minutes", o.BufferBeforeMinutes, booking.MaxBufferMinutes) &&
+		set("buffer_after_minutes", o.BufferAfterMinutes, booking.MaxBufferMinutes) &&
+		set("min_notice_minutes", o.MinNoticeMinutes, booking.MaxMinNoticeMinutes) &&
+		set("max_days_in_advance", o.MaxDaysInAdvance, booking.MaxHorizonDays)
+	return columns, ok
+}
+
+// schedulingRulesError is the message for rule values outside their bounds.
+const schedulingRulesError = "Buffers must be 0-240 minutes, minimum notice 0-43200 minutes and max_days_in_advance 0-730 (0 for no limit)"
+
+// GetSchedulingRules handles GET /api/talents/:talent_id/scheduling-rules and
+// returns the talent's buffers, minimum notice and booking horizon.
+func GetSchedulingRules(c *gin.Context) {
+	talentID := c.Param("talent_id")
+	rules, err := booking.TalentRules(config.DB, talentID)
+	if err != nil {
+		log.Printf("Error fetching scheduling rules of talent %s: %v\n", talentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheduling rules"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"talent_id": talentID, "rules": rules})
+}
+
+// UpdateSchedulingRules handles PUT /api/talents/:talent_id/scheduling-rules.
+// The rules apply to new bookings and to the slots offered from now on; existing
+// bookings are left as they are.
+func UpdateSchedulingRules(c *gin.Context) {
+	talentID := c.Param("talent_id")
+	if !authorizeTalent(c, talentID) {
+		return
+	}
+
+	var rules booking.Rules
+	if err := c.ShouldBindJSON(&rules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := rules.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": schedulingRulesError})
+		return
+	}
+
+	settings := models.TalentSchedulingRules{
+		TalentID:            talentID,
+		BufferBeforeMinutes: rules.BufferBefore,
+		BufferAfterMinutes:  rules.BufferAfter,
+		MinNoticeMinutes:    rules.MinNotice,
+		MaxDaysInAdvance:    rules.MaxDaysInAdvance,
+	}
+	if err := config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "talent_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"buffer_before_minutes", "buffer_after_minutes", "min_notice_minutes", "max_days_in_advance", "updated_at"}),
+	}).Create(&settings).Error; err != nil {
+		log.Printf("Error saving scheduling rules of talent %s: %v\n", talentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save scheduling rules"})
+		return
+	}
+	booking.InvalidateSnapshot(talentID)
+	c.JSON(http.StatusOK, gin.H{"message": "Scheduling rules updated", "talent_id": talentID, "rules": rules})
+}
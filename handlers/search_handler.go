@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"taas-api/booking"
+	"taas-api/config"
+	"taas-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchAvailability handles GET /api/search/availability and answers "who is
+// free Thursday 3pm?": the talents with a card of the given duration (minutes)
+// that can be booked between from and to. from and to are RFC 3339 times or
+// "YYYY-MM-DDTHH:MM" in time_zone (default the signed-in user's zone). Optional
+// filters are category, skills (comma-separated), min_price, max_price and limit.
+func SearchAvailability(c *gin.Context) {
+	timeZone := c.Query("time_zone")
+	if timeZone == "" {
+		var err error
+		if timeZone, err = userZoneName(c); err != nil {
+			respondZoneError(c, err)
+			return
+		}
+	}
+	loc, err := booking.LoadZone(timeZone)
+	if err != nil {
+		respondZoneError(c, err)
+		return
+	}
+
+	parseTime := func(param string) (time.Time, bool) {
+		value := c.Query(param)
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t, true
+		}
+		if t, err := time.ParseInLocation("2006-01-02T15:04", value, loc); err == nil {
+			return t, true
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be an RFC 3339 time or YYYY-MM-DDTHH:MM"})
+		return time.Time{}, false
+	}
+	from, ok := parseTime("from")
+	if !ok {
+		return
+	}
+	to, ok := parseTime("to")
+	if !ok {
+		return
+	}
+
+	query := booking.SearchQuery{
+		From:     from,
+		To:       to,
+		Category: c.Query("category"),
+		UserID:   middleware.UserID(c),
+	}
+	if skills := c.Query("skills"); skills != "" {
+		query.Skills = strings.Split(skills, ",")
+	}
+	for param, target := range map[string]*int{
+		"duration": &query.Duration, "min_price": &query.MinPrice, "max_price": &query.MaxPrice, "limit": &query.Limit,
+	} {
+		if value := c.Query(param); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+				return
+			}
+			*target = n
+		}
+	}
+
+	matches, err := booking.Search(config.DB, query, time.Now())
+	if errors.Is(err, booking.ErrInvalidSearch) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "duration is required and the window must end after it starts, at most 14 days later"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error searching availability: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search availability"})
+		return
+	}
+
+	// Show every slot on the requester's clock
+	for i := range matches {
+		for j := range matches[i].Cards {
+			for k, slot := range matches[i].Cards[j].Slots {
+				matches[i].Cards[j].Slots[k].Start, matches[i].Cards[j].Slots[k].End = slot.Start.In(loc), slot.End.In(loc)
+			}
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": matches, "time_zone": booking.ZoneName(loc)})
+}
@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"taas-api/booking"
+	"taas-api/config"
+	"taas-api/middleware"
+	"taas-api/models"
+	"taas-api/policy"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"storj.io/common/uuid"
+)
+
+// Scopes for changing one occurrence of a series or the rest of it.
+const (
+	seriesScopeOccurrence = "occurrence"
+	seriesScopeFuture     = "future"
+)
+
+var (
+	// errSeriesConflict rolls back a series change when one occurrence cannot move.
+	errSeriesConflict = errors.New("series occurrence conflicts")
+	// errSeriesSplit rolls back a series change when the new time of an
+	// occurrence falls on two days in the talent's time zone.
+	errSeriesSplit = errors.New("series occurrence splits across days")
+)
+
+// CreateSeriesRequest is the body of POST /api/booking-series.
+type CreateSeriesRequest struct {
+	CardID          string `json:"card_id" binding:"required"` // The title and duration come from the card
+	TalentID        string `json:"talent_id" binding:"required"`
+	SessionType     string `json:"session_type" binding:"required"` // Enum: CoffeeCall, Regular
+	SpecialRequests string `json:"special_requests,omitempty"`
+	Frequency       string `json:"frequency" binding:"required"`   // weekly or biweekly
+	StartDate       string `json:"start_date" binding:"required"`  // First occurrence, YYYY-MM-DD
+	TimeSlot        string `json:"time_slot" binding:"required"`   // "18:00-19:00"
+	Occurrences     int    `json:"occurrences" binding:"required"` // Number of sessions
+	AllowPartial    bool   `json:"allow_partial"`                  // Book the free occurrences even if some collide
+	TimeZone        string `json:"time_zone"`                      // Zone of start_date and time_slot; defaults to the user's
+}
+
+// CreateBookingSeries handles POST /api/booking-series, e.g. every Tuesday 18:00
+// for 8 weeks. Occurrences are linked by a series ID. Colliding occurrences are
+// reported; unless allow_partial is set, nothing is booked when any collides.
+func CreateBookingSeries(c *gin.Context) {
+	var req CreateSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	startDate, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format"})
+		return
+	}
+	dates, err := booking.SeriesDates(startDate, req.Frequency, req.Occurrences)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	talentZone, requesterZone, err := requestZones(c, req.TalentID, req.TimeZone)
+	if err != nil {
+		respondZoneError(c, err)
+		return
+	}
+	// Every occurrence is converted on its own so it keeps the requested local
+	// time across daylight saving changes
+	var inputs []BookingSlotInput
+	for _, date := range dates {
+		inputs = append(inputs, BookingSlotInput{BookingDate: date.Format("2006-01-02"), TimeSlots: []string{req.TimeSlot}})
+	}
+	days, slots, err := parseBookingSlots(inputs, requesterZone, talentZone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	card, ok := bookingCard(c, req.CardID, req.TalentID)
+	if !ok {
+		return
+	}
+	if err := checkSlotLengths(inputs[:1], card); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := middleware.UserID(c)
+	var user models.Users_ref
+	if err := config.DB.Where("user_id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	if missing := missingBookingVerification(user); len(missing) > 0 {
+		policy.Forbid(c, "Please verify your "+strings.Join(missing, " and ")+" before booking")
+		return
+	}
+
+	seriesID, err := uuid.New()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate series ID"})
+		return
+	}
+
+	var created []models.BookingRequests
+	var conflicts []booking.Conflict
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := booking.LockTalent(tx, req.TalentID); err != nil {
+			return err
+		}
+		var err error
+		conflicts, err = booking.CheckSlots(tx, req.TalentID, req.CardID, userID, slots)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 && (!req.AllowPartial || len(conflicts) == len(slots)) {
+			return nil
+		}
+
+		colliding := make(map[string]bool)
+		for _, conflict := range conflicts {
+			colliding[conflict.Date] = true
+		}
+		for _, day := range days {
+			if colliding[day.Date.Format("2006-01-02")] {
+				continue
+			}
+			bookingID, err := uuid.New()
+			if err != nil {
+				return err
+			}
+			b := models.BookingRequests{
+				BookingID:       bookingID.String(),
+				CardID:          req.CardID,
+				CardTitle:       card.CardTitle,
+				UserID:          userID,
+				TalentID:        req.TalentID,
+				SessionType:     models.SessionType(req.SessionType),
+				BookedTime:      day.Ranges,
+				BookingDate:     day.Date,
+				PaymentStatus:   models.Pending, // Only the payment flow marks a booking Paid
+				SpecialRequests: req.SpecialRequests,
+				SeriesID:        seriesID.String(),
+			}
+			if err := booking.Create(tx, &b, booking.Actor{ID: userID, Role: booking.ActorUser}); err != nil {
+				return err
+			}
+			created = append(created, b)
+		}
+		return tx.Create(&models.BookingSeries{
+			SeriesID:    seriesID.String(),
+			UserID:      userID,
+			TalentID:    req.TalentID,
+			CardID:      req.CardID,
+			Frequency:   req.Frequency,
+			StartDate:   created[0].BookingDate, // The first occurrence booked; skipped ones are not part of the series
+			TimeSlot:    created[0].BookedTime,
+			Occurrences: len(created), // Occurrences skipped with allow_partial are not counted
+		}).Error
+	})
+	if errors.Is(err, booking.ErrTalentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Talent not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating booking series for user %s: %v\n", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking series"})
+		return
+	}
+	if len(created) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Some occurrences are not available", "conflicts": renderConflicts(conflicts, talentZone, requesterZone)})
+		return
+	}
+	booking.InvalidateSnapshot(req.TalentID)
+	c.JSON(http.StatusCreated, gin.H{
+		"message":   "Booking series created",
+		"series_id": seriesID.String(),
+		"bookings":  created,
+		"skipped":   renderConflicts(conflicts, talentZone, requesterZone),
+	})
+}
+
+// GetBookingSeries handles GET /api/booking-series/:id for the booker, the talent
+// and admins.
+func GetBookingSeries(c *gin.Context) {
+	var series models.BookingSeries
+	if err := config.DB.Where("series_id = ?", c.Param("id")).First(&series).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Series not found"})
+		return
+	}
+	roles, err := bookingActorRoles(c, models.BookingRequests{UserID: series.UserID, TalentID: series.TalentID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch series"})
+		return
+	}
+	if len(roles) == 0 {
+		policy.Forbid(c, "You are not allowed to view this series")
+		return
+	}
+	var occurrences []models.BookingRequests
+	if err := config.DB.Where("series_id = ?", series.SeriesID).Order("booking_date").Find(&occurrences).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch series"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"series": series, "bookings": occurrences})
+}
+
+// seriesScope reads the ?scope= parameter of cancel and reschedule.
+func seriesScope(c *gin.Context, b models.BookingRequests) (string, bool) {
+	scope := c.DefaultQuery("scope", seriesScopeOccurrence)
+	if scope != seriesScopeOccurrence && scope != seriesScopeFuture {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be 'occurrence' or 'future'"})
+		return "", false
+	}
+	if scope == seriesScopeFuture && b.SeriesID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This booking is not part of a series"})
+		return "", false
+	}
+	return scope, true
+}
+
+// cancelFutureOccurrences cancels b and every later occurrence of its series in
+// one transaction.
+func cancelFutureOccurrences(c *gin.Context, b models.BookingRequests, actor booking.Actor, reason string) {
+	var cancelled []models.BookingRequests
+	var refunds []booking.CancelResult
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		occurrences, err := booking.FutureOccurrences(tx, b.SeriesID, b.BookingDate)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, o := range occurrences {
+			done, refund, err := booking.Cancel(tx, o.BookingID, actor, reason, now)
+			if err != nil {
+				return err
+			}
+			cancelled = append(cancelled, done)
+			refunds = append(refunds, refund)
+		}
+		return nil
+	})
+	if err != nil {
+		respondTransitionError(c, err, actor)
+		return
+	}
+	booking.InvalidateSnapshot(b.TalentID)
+	for _, o := range cancelled {
+		offerFreedTime(o.TalentID, o.BookingDate, o.BookedTime)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Series occurrences cancelled", "bookings": cancelled, "refunds": refunds})
+}
+
+// rescheduleFutureOccurrences moves b to the requested date and time slots and
+// shifts every later occurrence of its series by the same number of days to the
+// same time. Every occurrence is converted on its own so it keeps the requested
+// local time across daylight saving changes. Either all occurrences move or none.
+// newDate is the requested date on the talent's calendar. Moves that need the
+// talent's approval are refused: each would wait on its own hold while the
+// occurrences stay put, so the shifted ones would collide with the next.
+func rescheduleFutureOccurrences(c *gin.Context, b models.BookingRequests, input BookingSlotInput, newDate time.Time, actor booking.Actor, reason string, talentZone, requesterZone *time.Location) {
+	shift := int(newDate.Sub(b.BookingDate).Hours() / 24)
+	requested, err := time.Parse("2006-01-02", input.BookingDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking_date format"})
+		return
+	}
+	var card models.ServiceCard
+	if err := config.DB.Unscoped().Where("card_id = ?", b.CardID).First(&card).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch card"})
+		return
+	}
+	if booking.NeedsApproval(card, actor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Moves of this card need the talent's approval; reschedule the occurrences one at a time"})
+		return
+	}
+
+	var outcomes []booking.RescheduleOutcome
+	var conflicts []booking.Conflict
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		occurrences, err := booking.FutureOccurrences(tx, b.SeriesID, b.BookingDate)
+		if err != nil {
+			return err
+		}
+		// Move the last occurrence first when shifting later (and the first when
+		// shifting earlier) so occurrences do not collide with each other
+		if shift > 0 {
+			for i, j := 0, len(occurrences)-1; i < j; i, j = i+1, j-1 {
+				occurrences[i], occurrences[j] = occurrences[j], occurrences[i]
+			}
+		}
+		now := time.Now()
+		for _, o := range occurrences {
+			offset := int(o.BookingDate.Sub(b.BookingDate).Hours() / 24)
+			days, _, err := parseBookingSlots([]BookingSlotInput{{
+				BookingDate: requested.AddDate(0, 0, offset).Format("2006-01-02"),
+				TimeSlots:   input.TimeSlots,
+			}}, requesterZone, talentZone)
+			if err != nil || len(days) != 1 {
+				return errSeriesSplit
+			}
+			outcome, err := booking.Reschedule(tx, o.BookingID, days[0].Date, days[0].Ranges, actor, reason, now)
+			if err != nil {
+				return err
+			}
+			conflicts = append(conflicts, outcome.Conflicts...)
+			outcomes = append(outcomes, outcome)
+		}
+		if len(conflicts) > 0 {
+			return errSeriesConflict
+		}
+		return nil
+	})
+	if errors.Is(err, errSeriesSplit) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The new time of every occurrence must fall on one day in the talent's time zone"})
+		return
+	}
+	if errors.Is(err, errSeriesConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Some occurrences cannot be moved to the new time", "conflicts": renderConflicts(conflicts, talentZone, requesterZone)})
+		return
+	}
+	if err != nil {
+		respondRescheduleError(c, err)
+		return
+	}
+	booking.InvalidateSnapshot(b.TalentID)
+	c.JSON(http.StatusOK, gin.H{"message": "Series occurrences rescheduled", "results": outcomes})
+}
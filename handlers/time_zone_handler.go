@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"taas-api/booking"
+	"taas-api/config"
+	"taas-api/middleware"
+	"taas-api/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errRezoneConflict rolls back a talent's time zone change when bookings would
+// cross midnight in the new zone.
+var errRezoneConflict = errors.New("time zone change conflicts with bookings")
+
+// requestZones returns the talent's time zone, in which the schedule is stored,
+// and the zone the request's dates and times are read and shown in: the given
+// time_zone, else the signed-in user's zone, else the talent's.
+func requestZones(c *gin.Context, talentID string, timeZone string) (*time.Location, *time.Location, error) {
+	talent, err := booking.TalentZone(config.DB, talentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if timeZone == "" {
+		if timeZone, err = userZoneName(c); err != nil {
+			return nil, nil, err
+		}
+	}
+	if timeZone == "" {
+		return talent, talent, nil
+	}
+	requester, err := booking.LoadZone(timeZone)
+	if err != nil {
+		return nil, nil, err
+	}
+	return talent, requester, nil
+}
+
+// userZoneName returns the time zone the signed-in user has chosen, or "".
+func userZoneName(c *gin.Context) (string, error) {
+	userID := middleware.UserID(c)
+	if userID == "" {
+		return "", nil
+	}
+	var user models.Users_ref
+	if err := config.DB.Select("time_zone").Where("user_id = ?", userID).First(&user).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	return user.TimeZone, nil
+}
+
+// respondZoneError answers a failed requestZones.
+func respondZoneError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, booking.ErrInvalidTimeZone):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "time_zone must be an IANA time zone such as Europe/Berlin"})
+	case errors.Is(err, booking.ErrTalentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Talent not found"})
+	default:
+		log.Printf("Error resolving time zones: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve time zone"})
+	}
+}
+
+// renderConflicts shows conflicts, which are in the talent's zone, in the
+// requester's zone.
+func renderConflicts(conflicts []booking.Conflict, talent, requester *time.Location) []booking.Conflict {
+	rendered := make([]booking.Conflict, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		date, err := time.Parse("2006-01-02", conflict.Date)
+		if err == nil {
+			var r models.TimeRange
+			if date, r, err = booking.Render(date, models.TimeRange{StartTime: conflict.StartTime, EndTime: conflict.EndTime}, talent, requester); err == nil {
+				conflict.Date, conflict.StartTime, conflict.EndTime = date.Format("2006-01-02"), r.StartTime, r.EndTime
+			}
+		}
+		rendered = append(rendered, conflict)
+	}
+	return rendered
+}
+
+// UpdateMyTimeZone handles PUT /api/me/time-zone. The zone is used to read and
+// show times for the signed-in user; "" shows every talent in their own zone.
+func UpdateMyTimeZone(c *gin.Context) {
+	var input struct {
+		TimeZone string `json:"time_zone"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.TimeZone != "" {
+		if _, err := booking.LoadZone(input.TimeZone); err != nil {
+			respondZoneError(c, err)
+			return
+		}
+	}
+
+	userID := middleware.UserID(c)
+	if err := config.DB.Model(&models.Users_ref{}).Where("user_id = ?", userID).Update("time_zone", input.TimeZone).Error; err != nil {
+		log.Printf("Error updating time zone of user %s: %v\n", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update time zone"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Time zone updated", "time_zone": input.TimeZone})
+}
+
+// UpdateTalentTimeZone handles PUT /api/talents/:talent_id/time-zone. Booked
+// sessions keep their time and are moved onto the new wall clock; availability
+// does too unless keep_wall_clock is set.
+func UpdateTalentTimeZone(c *gin.Context) {
+	talentID := c.Param("talent_id")
+	if !authorizeTalent(c, talentID) {
+		return
+	}
+
+	var input struct {
+		TimeZone      string `json:"time_zone" binding:"required"`
+		KeepWallClock bool   `json:"keep_wall_clock"` // Availability keeps its "HH:MM" times instead of its instants
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	loc, err := booking.LoadZone(input.TimeZone)
+	if err != nil {
+		respondZoneError(c, err)
+		return
+	}
+
+	var conflicts []booking.Conflict
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		conflicts, err = booking.Rezone(tx, talentID, loc, input.KeepWallClock, time.Now())
+		if err == nil && len(conflicts) > 0 {
+			return errRezoneConflict
+		}
+		return err
+	})
+	switch {
+	case errors.Is(err, errRezoneConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "Some bookings would cross midnight in the new time zone", "conflicts": conflicts})
+	case errors.Is(err, booking.ErrTalentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Talent not found"})
+	case err != nil:
+		log.Printf("Error changing time zone of talent %s: %v\n", talentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update time zone"})
+	default:
+		booking.InvalidateSnapshot(talentID)
+		c.JSON(http.StatusOK, gin.H{"message": "Time zone updated", "talent_id": talentID, "time_zone": input.TimeZone})
+	}
+}
@@ -3,7 +3,9 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"taas-api/booking"
 	"taas-api/config"
+	"taas-api/middleware"
 	"taas-api/models"
 
 	"github.com/gin-gonic/gin"
@@ -24,6 +26,9 @@ import (
 var validate *validator.Validate = validator.New()
 
 // Signup Handler
+//
+// Signup is kept for clients that still use the legacy email/password form. It
+// creates a canonical Users_ref account without name or phone.
 func Signup(c *gin.Context) {
 	var input struct {
 		Email    string `json:"email"`
@@ -37,7 +42,7 @@ func Signup(c *gin.Context) {
 	}
 
 	//Check if email already exists
-	var existingUser models.User
+	var existingUser models.Users_ref
 	if err := config.DB.Where("email = ?", input.Email).First(&existingUser).Error; err == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Email already exists"})
 		return
@@ -58,10 +63,11 @@ func Signup(c *gin.Context) {
 	}
 
 	// Create the user
-	user := models.User{
-		ID:       uniqueUserID.String(), // Convert to UUI
-		Email:    input.Email,
-		Password: string(hashedPassword),
+	user := models.Users_ref{
+		UserID:      uniqueUserID.String(), // Convert to UUI
+		Email:       input.Email,
+		Password:    string(hashedPassword),
+		AccountType: models.AccountTypeUser,
 	}
 
 	// Save to database
@@ -78,33 +84,10 @@ func Signup(c *gin.Context) {
 }
 
 // Login Handler
+//
+// Login is a compatibility alias of SignIn; both authenticate against Users_ref.
 func Login(c *gin.Context) {
-	var input struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
-
-	// Validate request body
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	// Fetch user by email
-	var user models.User
-	if err := config.DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
-		return
-	}
-	// Compare passwords
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
-		return
-	}
-	// Respond with user_id and success message
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"userId":  user.ID, // Include user ID in the response
-	})
+	SignIn(c)
 }
 
 // RegisterUser handles user registration requests.
@@ -127,6 +110,15 @@ func RegisterUser(c *gin.Context) {
 		return
 	}
 
+	// Only "User" and "Talent" accounts can be self-registered; admins are promoted by another admin
+	if input.AccountType == "" {
+		input.AccountType = models.AccountTypeUser
+	}
+	if input.AccountType != models.AccountTypeUser && input.AccountType != models.AccountTypeTalent {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account_type. Allowed values are 'User' or 'Talent'"})
+		return
+	}
+
 	//Check if email already exists
 	var existingUser models.Users_ref
 	if err := config.DB.Where("email = ?", input.Email).First(&existingUser).Error; err == nil {
@@ -152,7 +144,7 @@ func RegisterUser(c *gin.Context) {
 		UserID:      uniqueUserID.String(), // Convert to UUI
 		FirstName:   input.FirstName,
 		LastName:    input.LastName,
-		Phone:       input.Phone,
+		Phone:       optionalString(input.Phone),
 		AccountType: input.AccountType,
 		Email:       input.Email,
 		Password:    string(hashedPassword),
@@ -164,6 +156,11 @@ func RegisterUser(c *gin.Context) {
 		return
 	}
 
+	// Send the first email verification code; the user can request another one later
+	if err := sendVerificationCode(config.DB, user, models.VerificationChannelEmail); err != nil {
+		fmt.Println("Error sending email verification code:", err)
+	}
+
 	// Return success response with user ID
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User registered successfully",
@@ -183,21 +180,46 @@ func SignIn(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// Reject locked out IPs/accounts and slow down repeated failures
+	if !beginLoginAttempt(c, input.Email) {
+		return
+	}
 	// Fetch user by email
 	var user models.Users_ref
 	if err := config.DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		loginAttemptFailed(c, input.Email, "", models.AuthEventLoginFailed, "unknown email")
 		return
 	}
 	// Compare passwords
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		loginAttemptFailed(c, input.Email, user.UserID, models.AuthEventLoginFailed, "wrong password")
+		return
+	}
+	// Accounts with two-factor authentication get a challenge instead of a session
+	purpose, err := mfaRequiredFor(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read MFA settings"})
 		return
 	}
-	// Respond with user_id and success message
+	if purpose != "" {
+		mfaChallengeResponse(c, user, purpose)
+		return
+	}
+
+	// Start a new session for the authenticated user
+	tokens, err := issueSession(config.DB, user, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	loginAttemptSucceeded(c, user.Email, user.UserID)
+	// Respond with user_id, tokens and success message
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"userId":  user.UserID, // Include user ID in the response
+		"message":       "Login successful",
+		"userId":        user.UserID, // Include user ID in the response
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 	})
 }
 
@@ -205,7 +227,6 @@ func SignIn(c *gin.Context) {
 func RegisterTalent(c *gin.Context) {
 	// Parse incoming JSON data into a struct
 	var talent struct {
-		UserID          string   `json:"user_id" binding:"required"`
 		TalentName      string   `json:"talent_name" binding:"required"`
 		Category        string   `json:"category" binding:"required"`
 		Bio             string   `json:"bio" binding:"required"`
@@ -213,6 +234,7 @@ func RegisterTalent(c *gin.Context) {
 		Skills          []string `json:"skills" binding:"required"` // Array of skills from the request
 		ProfileImage    string   `json:"profile_image"`             // Base64 encoded or file path
 		ExperienceLevel string   `json:"experience_level" binding:"required"`
+		TimeZone        string   `json:"time_zone"` // IANA zone the schedule is kept in
 	}
 
 	// Bind JSON data and validate
@@ -221,6 +243,11 @@ func RegisterTalent(c *gin.Context) {
 		return
 	}
 
+	if _, err := booking.LoadZone(talent.TimeZone); err != nil {
+		respondZoneError(c, err)
+		return
+	}
+
 	// Join skills into a single comma-separated string
 	skillsString := strings.Join(talent.Skills, ",")
 
@@ -239,7 +266,7 @@ func RegisterTalent(c *gin.Context) {
 	}
 	// Create TalentRegistration object for saving to DB
 	talentRecord := models.TalentRegistration{
-		UserID:          talent.UserID,
+		UserID:          middleware.UserID(c),
 		TalentID:        uniquetalentid.String(),
 		TalentName:      talent.TalentName,
 		Category:        talent.Category,
@@ -248,6 +275,7 @@ func RegisterTalent(c *gin.Context) {
 		PortfolioLink:   talent.PortfolioURL,
 		Skills:          skillsString,
 		ProfileImageURL: imagePath,
+		TimeZone:        talent.TimeZone,
 	}
 
 	// Save to database
@@ -329,3 +357,11 @@ func handleImageUpload(base64Image string) (string, error) {
 
 	return imagePath, nil
 }
+
+// optionalString maps an empty string to nil for nullable columns.
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"taas-api/config"
+	"taas-api/mailer"
+	"taas-api/middleware"
+	"taas-api/models"
+	"taas-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	verificationCodeTTL         = 15 * time.Minute
+	verificationCodeDigits      = 6
+	verificationMaxAttempts     = 5
+	verificationResendInterval  = time.Minute // Minimum time between two codes on one channel
+	verificationMaxSendsPerHour = 5
+)
+
+var (
+	errAlreadyVerified       = errors.New("already verified")
+	errNoVerificationTarget  = errors.New("nothing to verify")
+	errVerificationCodeWrong = errors.New("invalid verification code")
+)
+
+// verificationThrottledError is returned when a code was requested too often.
+type verificationThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *verificationThrottledError) Error() string {
+	return fmt.Sprintf("verification code requested too often, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// sendVerificationCode creates a fresh code for the channel, supersedes older
+// ones and delivers it by email or SMS, subject to resend throttling.
+func sendVerificationCode(db *gorm.DB, user models.Users_ref, channel string) error {
+	var target string
+	switch channel {
+	case models.VerificationChannelEmail:
+		if user.EmailVerifiedAt != nil {
+			return errAlreadyVerified
+		}
+		target = user.Email
+	case models.VerificationChannelPhone:
+		if user.PhoneVerifiedAt != nil {
+			return errAlreadyVerified
+		}
+		if user.Phone != nil {
+			target = *user.Phone
+		}
+	}
+	if target == "" {
+		return errNoVerificationTarget
+	}
+
+	// Throttle: one code per interval, and a cap per rolling hour
+	var recent []models.VerificationCode
+	if err := db.Where("user_id = ? AND channel = ? AND created_at > ?", user.UserID, channel, time.Now().Add(-time.Hour)).
+		Order("created_at DESC").Find(&recent).Error; err != nil {
+		return err
+	}
+	if len(recent) > 0 {
+		if wait := verificationResendInterval - time.Since(recent[0].CreatedAt); wait > 0 {
+			return &verificationThrottledError{RetryAfter: wait}
+		}
+	}
+	if len(recent) >= verificationMaxSendsPerHour {
+		oldest := recent[len(recent)-1].CreatedAt
+		return &verificationThrottledError{RetryAfter: time.Until(oldest.Add(time.Hour))}
+	}
+
+	code, err := utils.GenerateNumericCode(verificationCodeDigits)
+	if err != nil {
+		return err
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.VerificationCode{}).
+			Where("user_id = ? AND channel = ? AND used_at IS NULL", user.UserID, channel).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.VerificationCode{
+			UserID:    user.UserID,
+			Channel:   channel,
+			Target:    target,
+			CodeHash:  verificationCodeHash(user.UserID, code),
+			ExpiresAt: time.Now().Add(verificationCodeTTL),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("Your verification code is %s. It expires in %d minutes.", code, int(verificationCodeTTL.Minutes()))
+	if channel == models.VerificationChannelEmail {
+		return config.Mailer.Send(context.Background(), mailer.Message{
+			To:      target,
+			Subject: "Verify your email address",
+			Body:    text + "\n",
+		})
+	}
+	return config.SMS.SendSMS(context.Background(), target, text)
+}
+
+// verificationCodeHash binds a code to its account so equal codes of different users hash differently.
+func verificationCodeHash(userID, code string) string {
+	return utils.HashToken(userID + ":" + code)
+}
+
+// SendVerificationCode handles POST /api/verify/:channel/send for "email" or "phone".
+func SendVerificationCode(c *gin.Context) {
+	channel := c.Param("channel")
+	if channel != models.VerificationChannelEmail && channel != models.VerificationChannelPhone {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Channel must be 'email' or 'phone'"})
+		return
+	}
+
+	var user models.Users_ref
+	if err := config.DB.Where("user_id = ?", middleware.UserID(c)).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	err := sendVerificationCode(config.DB, user, channel)
+	var throttled *verificationThrottledError
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "Verification code sent", "expires_in": int(verificationCodeTTL.Seconds())})
+	case errors.As(err, &throttled):
+		c.Header("Retry-After", fmt.Sprintf("%d", int(throttled.RetryAfter.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": throttled.Error()})
+	case errors.Is(err, errAlreadyVerified):
+		c.JSON(http.StatusConflict, gin.H{"error": "This " + channel + " is already verified"})
+	case errors.Is(err, errNoVerificationTarget):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No " + channel + " is registered for this account"})
+	default:
+		log.Printf("Error sending %s verification code to user %s: %v\n", channel, user.UserID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification code"})
+	}
+}
+
+// ConfirmVerificationCode handles POST /api/verify/:channel/confirm and marks the
+// email or phone of the authenticated account as verified.
+func ConfirmVerificationCode(c *gin.Context) {
+	channel := c.Param("channel")
+	if channel != models.VerificationChannelEmail && channel != models.VerificationChannelPhone {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Channel must be 'email' or 'phone'"})
+		return
+	}
+
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	userID := middleware.UserID(c)
+	rejected := false
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var code models.VerificationCode
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND channel = ? AND used_at IS NULL", userID, channel).
+			Order("created_at DESC").First(&code).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errVerificationCodeWrong
+			}
+			return err
+		}
+		if time.Now().After(code.ExpiresAt) || code.Attempts >= verificationMaxAttempts {
+			return errVerificationCodeWrong
+		}
+
+		expected := verificationCodeHash(userID, input.Code)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code.CodeHash)) != 1 {
+			// Commit the failed attempt; the request is rejected below
+			rejected = true
+			return tx.Model(&code).Update("attempts", gorm.Expr("attempts + 1")).Error
+		}
+
+		now := time.Now()
+		if err := tx.Model(&code).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		column := "email_verified_at"
+		if channel == models.VerificationChannelPhone {
+			column = "phone_verified_at"
+		}
+		return tx.Model(&models.Users_ref{}).Where("user_id = ?", userID).Update(column, now).Error
+	})
+
+	if rejected || errors.Is(err, errVerificationCodeWrong) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification code"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error confirming %s verification for user %s: %v\n", channel, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Verification successful", "channel": channel})
+}
+
+// missingBookingVerification returns the contact details the account still has to
+// verify before it may book, according to BOOKING_REQUIRES_VERIFICATION.
+func missingBookingVerification(user models.Users_ref) []string {
+	requirement := config.BookingVerificationRequirement()
+	var missing []string
+	if (requirement == config.VerificationEmail || requirement == config.VerificationBoth) && user.EmailVerifiedAt == nil {
+		missing = append(missing, models.VerificationChannelEmail)
+	}
+	if (requirement == config.VerificationPhone || requirement == config.VerificationBoth) && user.PhoneVerifiedAt == nil {
+		missing = append(missing, models.VerificationChannelPhone)
+	}
+	return missing
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"taas-api/booking"
+	"taas-api/config"
+	"taas-api/mailer"
+	"taas-api/middleware"
+	"taas-api/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// JoinWaitlist handles POST /api/waitlist and puts the authenticated user in line
+// for a card of a talent on one date.
+func JoinWaitlist(c *gin.Context) {
+	var input struct {
+		TalentID    string `json:"talent_id" binding:"required"`
+		CardID      string `json:"card_id" binding:"required"`
+		SessionType string `json:"session_type" binding:"required"` // Enum: CoffeeCall, Regular
+		Date        string `json:"date" binding:"required"`         // YYYY-MM-DD
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	waitDate, err := time.Parse("2006-01-02", input.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD."})
+		return
+	}
+	// Dates are the talent's calendar days
+	talentZone, err := booking.TalentZone(config.DB, input.TalentID)
+	if err != nil {
+		respondZoneError(c, err)
+		return
+	}
+	if waitDate.Before(booking.Day(time.Now(), talentZone)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The date is in the past"})
+		return
+	}
+
+	var card models.ServiceCard
+	if err := config.DB.Where("card_id = ? AND talent_id = ?", input.CardID, input.TalentID).First(&card).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Card not found for this talent"})
+		return
+	}
+
+	userID := middleware.UserID(c)
+	var existing models.WaitlistEntry
+	err = config.DB.Where("user_id = ? AND card_id = ? AND wait_date = ? AND status IN ?",
+		userID, input.CardID, waitDate, []string{models.WaitlistWaiting, models.WaitlistOffered}).First(&existing).Error
+	if err == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "You are already on the waitlist", "entry": existing})
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join waitlist"})
+		return
+	}
+
+	entry := models.WaitlistEntry{
+		UserID:      userID,
+		TalentID:    input.TalentID,
+		CardID:      input.CardID,
+		SessionType: models.SessionType(input.SessionType),
+		WaitDate:    waitDate,
+		Status:      models.WaitlistWaiting,
+	}
+	if err := config.DB.Create(&entry).Error; err != nil {
+		log.Printf("Error joining waitlist for user %s: %v\n", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join waitlist"})
+		return
+	}
+
+	// The place in line among the users waiting for the same card
+	var position int64
+	config.DB.Model(&models.WaitlistEntry{}).
+		Where("talent_id = ? AND card_id = ? AND wait_date = ? AND status = ? AND created_at <= ?",
+			entry.TalentID, entry.CardID, entry.WaitDate, models.WaitlistWaiting, entry.CreatedAt).
+		Count(&position)
+	c.JSON(http.StatusCreated, gin.H{"message": "Joined the waitlist", "entry": entry, "position": position})
+}
+
+// ListMyWaitlist handles GET /api/waitlist and returns the caller's open entries.
+func ListMyWaitlist(c *gin.Context) {
+	var entries []models.WaitlistEntry
+	if err := config.DB.Where("user_id = ? AND status IN ?", middleware.UserID(c),
+		[]string{models.WaitlistWaiting, models.WaitlistOffered}).Order("wait_date").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch waitlist"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// LeaveWaitlist handles DELETE /api/waitlist/:id. An outstanding offer is released.
+func LeaveWaitlist(c *gin.Context) {
+	var entry models.WaitlistEntry
+	if err := config.DB.Where("id = ? AND user_id = ? AND status IN ?", c.Param("id"), middleware.UserID(c),
+		[]string{models.WaitlistWaiting, models.WaitlistOffered}).First(&entry).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waitlist entry not found"})
+		return
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if entry.OfferHoldID != "" {
+			if err := tx.Model(&models.BookingHold{}).Where("hold_id = ? AND released_at IS NULL", entry.OfferHoldID).
+				Update("released_at", time.Now()).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&entry).Update("status", models.WaitlistLeft).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave waitlist"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Left the waitlist"})
+}
+
+// offerFreedTime offers freed or newly published time to the talent's waitlist
+// and notifies the users who got an offer. Errors are logged; the triggering
+// request has already succeeded.
+func offerFreedTime(talentID string, date time.Time, ranges models.TimeRanges) {
+	var offers []booking.Offer
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := booking.LockTalent(tx, talentID); err != nil {
+			return err
+		}
+		var err error
+		offers, err = booking.OfferFreedTime(tx, talentID, date, ranges, time.Now())
+		return err
+	})
+	if err != nil {
+		log.Printf("Error offering freed time of talent %s on %s to the waitlist: %v\n", talentID, date.Format("2006-01-02"), err)
+		return
+	}
+	NotifyWaitlistOffers(offers)
+}
+
+// NotifyWaitlistOffers emails every user who was offered a slot from the waitlist.
+func NotifyWaitlistOffers(offers []booking.Offer) {
+	for _, offer := range offers {
+		var user models.Users_ref
+		if err := config.DB.Where("user_id = ?", offer.Entry.UserID).First(&user).Error; err != nil {
+			log.Printf("Error loading waitlisted user %s: %v\n", offer.Entry.UserID, err)
+			continue
+		}
+		slot := offer.Hold.HeldTime[0]
+		msg := mailer.Message{
+			To:      user.Email,
+			Subject: "A slot opened up for " + offer.Hold.CardTitle,
+			Body: fmt.Sprintf("Good news! %s on %s from %s to %s is now available and reserved for you.\n\n"+
+				"Confirm it in the app within %d minutes (hold %s); after that it is offered to the next person in line.\n\n%s\n",
+				offer.Hold.CardTitle, offer.Hold.BookingDate.Format("2006-01-02"), slot.StartTime, slot.EndTime,
+				int(booking.WaitlistOfferDuration.Minutes()), offer.Hold.HoldID, appBaseURL()),
+		}
+		if err := config.Mailer.Send(context.Background(), msg); err != nil {
+			log.Printf("Error sending waitlist offer to user %s: %v\n", user.UserID, err)
+		}
+	}
+}
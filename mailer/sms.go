@@ -0,0 +1,63 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMSSender delivers short text messages to phone numbers.
+type SMSSender interface {
+	SendSMS(ctx context.Context, phone string, text string) error
+}
+
+// FileSMSSender writes every text message into a directory instead of sending it.
+// A real SMS provider can be plugged in by implementing SMSSender.
+type FileSMSSender struct {
+	dir string
+	mu  sync.Mutex
+	seq int
+}
+
+// NewFileSMSSender returns an SMSSender that drops messages into dir.
+func NewFileSMSSender(dir string) *FileSMSSender {
+	return &FileSMSSender{dir: dir}
+}
+
+// SendSMS implements SMSSender.
+func (s *FileSMSSender) SendSMS(ctx context.Context, phone string, text string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.seq++
+	seq := s.seq
+	s.mu.Unlock()
+
+	recipient := strings.NewReplacer("+", "", "/", "_", "\\", "_").Replace(phone)
+	name := fmt.Sprintf("%s_%03d_%s.sms.txt", time.Now().Format("20060102T150405"), seq, recipient)
+	return os.WriteFile(filepath.Join(s.dir, name), []byte("To: "+phone+"\n\n"+text+"\n"), 0o600)
+}
+
+// SMSFromEnv builds the SMS sender. Only the file driver exists today; it writes
+// to SMS_DROP_DIR (default "tmp/sms").
+func SMSFromEnv() (SMSSender, error) {
+	switch driver := os.Getenv("SMS_DRIVER"); driver {
+	case "", "file":
+		dir := os.Getenv("SMS_DROP_DIR")
+		if dir == "" {
+			dir = "tmp/sms"
+		}
+		return NewFileSMSSender(dir), nil
+	default:
+		return nil, fmt.Errorf("unknown SMS_DRIVER %q", driver)
+	}
+}
@@ -4,14 +4,20 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
+	"taas-api/booking"
 	"taas-api/config"
+	"taas-api/handlers"
 	"taas-api/routes"
 )
 
 func main() {
 	// Step 1: Connect to the database
 	config.ConnectDB()
+	config.InitMailer()
+	config.InitLimiter()
+	booking.StartHoldSweeper(config.DB, time.Minute, handlers.NotifyWaitlistOffers)
 
 	// Step 2: Setup routes
 	router := routes.SetupRoutes()
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"taas-api/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Require rejects the request with 403 unless the authenticated account type is
+// allowed to perform the action. It must run after AuthRequired.
+func Require(action policy.Action) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !policy.Can(AccountType(c), action) {
+			policy.Forbid(c, "Your account type is not allowed to perform this action")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,117 @@
+package models
+
+import "time"
+
+// RefreshToken is a single-use token that can be exchanged for a new access token.
+// Tokens issued from the same sign-in share a FamilyID so that reuse of a rotated
+// token can revoke the whole chain.
+type RefreshToken struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     string     `gorm:"size:64;not null;index" json:"user_id"`   // Owner of the session
+	FamilyID   string     `gorm:"size:64;not null;index" json:"family_id"` // Shared by all rotations of one sign-in
+	TokenHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"`   // SHA-256 of the raw token
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`              // Absolute expiry
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`                    // Set on rotation, logout or reuse detection
+	ReplacedBy string     `gorm:"size:64" json:"-"`                        // Hash of the token issued on rotation
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`        // Timestamp when issued
+}
+
+// PasswordResetToken is a single-use token emailed to a user who forgot their password.
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    string     `gorm:"size:64;not null;index" json:"user_id"` // Account being recovered
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"` // SHA-256 of the emailed token
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`            // Token is rejected after this time
+	UsedAt    *time.Time `json:"used_at,omitempty"`                     // Set once the token has been redeemed
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`      // Timestamp when requested
+}
+
+// Verification channels.
+const (
+	VerificationChannelEmail = "email"
+	VerificationChannelPhone = "phone"
+)
+
+// VerificationCode is a one-time code sent to prove ownership of an email address or phone number.
+type VerificationCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    string     `gorm:"size:64;not null;index:idx_verification_user_channel" json:"user_id"` // Account being verified
+	Channel   string     `gorm:"size:10;not null;index:idx_verification_user_channel" json:"channel"` // "email" or "phone"
+	Target    string     `gorm:"size:100;not null" json:"target"`                                     // Address or number the code was sent to
+	CodeHash  string     `gorm:"size:64;not null" json:"-"`                                           // SHA-256 of the code
+	Attempts  int        `gorm:"not null;default:0" json:"attempts"`                                  // Failed confirmation attempts
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`                                          // Code is rejected after this time
+	UsedAt    *time.Time `json:"used_at,omitempty"`                                                   // Set once confirmed or superseded
+	CreatedAt time.Time  `gorm:"autoCreateTime;index" json:"created_at"`                              // Timestamp when sent
+}
+
+// RecoveryCode is a single-use code that replaces a TOTP code when the authenticator is lost.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    string     `gorm:"size:64;not null;index" json:"user_id"` // Owner of the code
+	CodeHash  string     `gorm:"size:64;not null" json:"-"`             // SHA-256 of the code
+	UsedAt    *time.Time `json:"used_at,omitempty"`                     // Set once the code has been redeemed
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`      // Timestamp when generated
+}
+
+// Keys of AppSetting rows.
+const (
+	SettingRequireTalentMFA = "require_talent_mfa"
+)
+
+// AppSetting is an admin-managed runtime setting.
+type AppSetting struct {
+	Key       string    `gorm:"primaryKey;size:100" json:"key"`
+	Value     string    `gorm:"type:text;not null" json:"value"`
+	UpdatedBy string    `gorm:"size:64" json:"updated_by"`        // Admin who last changed the setting
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"` // Timestamp of the last change
+}
+
+// Auth event types recorded in auth_events.
+const (
+	AuthEventLoginSucceeded = "login_succeeded"
+	AuthEventLoginFailed    = "login_failed"
+	AuthEventMFAFailed      = "mfa_failed"
+	AuthEventLockedOut      = "locked_out"
+)
+
+// AuthEvent is an audit record of a sign-in attempt.
+type AuthEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    string    `gorm:"size:64;index" json:"user_id"`           // Empty when the email is unknown
+	Email     string    `gorm:"size:100;index" json:"email"`            // Email the attempt was made for
+	IP        string    `gorm:"size:64;index" json:"ip"`                // Client IP address
+	UserAgent string    `gorm:"size:255" json:"user_agent"`             // Client user agent
+	Event     string    `gorm:"size:30;not null" json:"event"`          // One of the AuthEvent* constants
+	Reason    string    `gorm:"size:255" json:"reason"`                 // Why the attempt failed, if it did
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"created_at"` // Timestamp of the attempt
+}
+
+// RateLimitHit is one failure counted by the Postgres rate-limit store.
+type RateLimitHit struct {
+	ID        uint      `gorm:"primaryKey"`
+	Key       string    `gorm:"size:150;not null;index:idx_rate_limit_key_time"`
+	CreatedAt time.Time `gorm:"not null;index:idx_rate_limit_key_time"`
+}
+
+// RateLimitLock is an active lockout in the Postgres rate-limit store.
+type RateLimitLock struct {
+	Key         string    `gorm:"primaryKey;size:150"`
+	LockedUntil time.Time `gorm:"not null"`
+}
+
+// APIKey is a long-lived credential for server-to-server integrations. The raw key
+// is "taas_<prefix>_<secret>"; the prefix is stored in clear for lookup and the
+// whole key only as a hash.
+type APIKey struct {
+	ID         uint        `gorm:"primaryKey" json:"id"`
+	UserID     string      `gorm:"size:64;not null;index" json:"user_id"`      // Owner; the key acts with this account's permissions
+	Name       string      `gorm:"size:100;not null" json:"name"`              // Label chosen by the owner
+	Prefix     string      `gorm:"size:16;not null;uniqueIndex" json:"prefix"` // Public lookup part of the key
+	KeyHash    string      `gorm:"size:64;not null" json:"-"`                  // SHA-256 of the full key
+	Scopes     StringSlice `gorm:"type:jsonb;not null" json:"scopes"`          // e.g. ["bookings:read", "schedule:write"]
+	LastUsedAt *time.Time  `json:"last_used_at,omitempty"`                     // Updated at most once a minute
+	ExpiresAt  *time.Time  `json:"expires_at,omitempty"`                       // Optional expiry
+	RevokedAt  *time.Time  `json:"revoked_at,omitempty"`                       // Set when the owner revokes the key
+	CreatedAt  time.Time   `gorm:"autoCreateTime" json:"created_at"`           // Timestamp when minted
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AvailabilityTemplate is a weekly recurring availability of a talent, e.g.
+// Monday to Friday 09:00-12:00 and 14:00-18:00. Its times are on the wall clock of
+// the talent's current time zone.
+type AvailabilityTemplate struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	TalentID       string         `gorm:"size:64;not null;index" json:"talent_id"`
+	Weekdays       Weekdays       `gorm:"type:jsonb;not null" json:"weekdays"` // 0 = Sunday ... 6 = Saturday
+	Slots          TimeRanges     `gorm:"type:jsonb;not null" json:"slots"`    // Ranges available on each of the weekdays
+	EffectiveFrom  time.Time      `gorm:"not null" json:"effective_from"`      // First day the template applies
+	EffectiveUntil *time.Time     `json:"effective_until,omitempty"`           // Last day it applies; open-ended when nil
+	CreatedAt      time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+// Kinds of AvailabilityException.
+const (
+	ExceptionOverride = "override" // Replaces the templates' slots on the date
+	ExceptionBlackout = "blackout" // No availability at all on the date
+)
+
+// AvailabilityException changes one date of a talent's weekly templates:
+// an override with its own slots, or a blackout day such as a holiday.
+type AvailabilityException struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	TalentID  string     `gorm:"size:64;not null;uniqueIndex:idx_availability_exception_date" json:"talent_id"`
+	Date      time.Time  `gorm:"not null;uniqueIndex:idx_availability_exception_date" json:"date"`
+	Kind      string     `gorm:"size:16;not null" json:"kind"` // override or blackout
+	Slots     TimeRanges `gorm:"type:jsonb" json:"slots"`      // Slots of an override
+	Reason    string     `gorm:"size:255" json:"reason,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Weekdays is a set of days of the week stored as a JSON array.
+type Weekdays []time.Weekday
+
+// Has reports whether day is one of the weekdays.
+func (w Weekdays) Has(day time.Weekday) bool {
+	for _, d := range w {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Value implements the driver.Valuer interface.
+func (w Weekdays) Value() (driver.Value, error) {
+	return json.Marshal(w)
+}
+
+// Scan implements the sql.Scanner interface.
+func (w *Weekdays) Scan(value interface{}) error {
+	if value == nil {
+		*w = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal weekdays: %v", value)
+	}
+	return json.Unmarshal(bytes, w)
+}
@@ -15,6 +15,7 @@ type AvailableTimeSlots struct {
 	TalentID       string         `json:"talent_id" binding:"required"`
 	AvailableDate  time.Time      `json:"available_date" binding:"required"`
 	AvailableSlots TimeRanges     `gorm:"type:jsonb" json:"available_slots" binding:"required"` // JSON format
+	TimeZone       string         `gorm:"size:64;not null;default:''" json:"time_zone"`         // IANA zone of the slots, the talent's zone
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `gorm:"index" json:"deleted_at"`
@@ -34,17 +35,27 @@ const (
 
 type BookingStatus string
 
+// Booking lifecycle: Requested → Accepted/Declined → Scheduled → InProgress →
+// Completed/NoShow/Cancelled. Allowed moves are defined in the booking package.
 const (
-	Scheduled BookingStatus = "Scheduled"
-	Completed BookingStatus = "Completed"
-	Cancelled BookingStatus = "Cancelled"
+	Requested  BookingStatus = "Requested"
+	Accepted   BookingStatus = "Accepted"
+	Declined   BookingStatus = "Declined"
+	Scheduled  BookingStatus = "Scheduled"
+	InProgress BookingStatus = "InProgress"
+	Completed  BookingStatus = "Completed"
+	NoShow     BookingStatus = "NoShow"
+	Cancelled  BookingStatus = "Cancelled"
 )
 
 type PaymentStatus string
 
 const (
-	Paid    PaymentStatus = "Paid"
-	Pending PaymentStatus = "Pending"
+	Paid              PaymentStatus = "Paid"
+	Pending           PaymentStatus = "Pending"
+	Refunded          PaymentStatus = "Refunded"          // Paid and refunded in full after cancellation
+	PartiallyRefunded PaymentStatus = "PartiallyRefunded" // Paid and refunded in part after cancellation
+	Voided            PaymentStatus = "Voided"            // Cancelled before payment; nothing will be charged
 )
 
 type BookingRequests struct {
@@ -57,14 +68,131 @@ type BookingRequests struct {
 	SessionType     SessionType    `gorm:"type:text" json:"session_type" binding:"required"`
 	BookedTime      TimeRanges     `gorm:"type:jsonb" json:"booked_time" binding:"required"` // combined start and end time
 	BookingDate     time.Time      `json:"booking_date" binding:"required"`
+	TimeZone        string         `gorm:"size:64;not null;default:''" json:"time_zone"` // IANA zone of BookingDate and BookedTime, the talent's zone
 	Status          BookingStatus  `gorm:"type:text" json:"status" binding:"required"`
 	PaymentStatus   PaymentStatus  `gorm:"type:text" json:"payment_status" binding:"required"`
 	SpecialRequests string         `json:"special_requests"`
+	RefundAmount    int            `gorm:"not null;default:0" json:"refund_amount"`    // Set on cancellation, same unit as ServiceCard.Price
+	RescheduleCount int            `gorm:"not null;default:0" json:"reschedule_count"` // Times the booking was moved
+	SeriesID        string         `gorm:"size:64;index" json:"series_id,omitempty"`   // BookingSeries this occurrence belongs to
+	CancelledAt     *time.Time     `json:"cancelled_at,omitempty"`                     // When the booking was cancelled
+	CancelledBy     string         `gorm:"size:16" json:"cancelled_by,omitempty"`      // Actor role that cancelled
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
 
+// Recurrence frequencies of a BookingSeries.
+const (
+	FrequencyWeekly   = "weekly"
+	FrequencyBiweekly = "biweekly"
+)
+
+// BookingSeries describes a recurring booking. Each occurrence is a
+// BookingRequests row carrying the SeriesID.
+type BookingSeries struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	SeriesID    string     `gorm:"size:64;not null;uniqueIndex" json:"series_id"`
+	UserID      string     `gorm:"size:64;not null;index" json:"user_id"`
+	TalentID    string     `gorm:"size:64;not null;index" json:"talent_id"`
+	CardID      string     `gorm:"size:64;not null" json:"card_id"`
+	Frequency   string     `gorm:"size:16;not null" json:"frequency"` // weekly or biweekly
+	StartDate   time.Time  `json:"start_date"`
+	TimeSlot    TimeRanges `gorm:"type:jsonb" json:"time_slot"`
+	Occurrences int        `gorm:"not null" json:"occurrences"` // Occurrences requested, including skipped ones
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// Kinds of a BookingHold.
+const (
+	HoldCheckout   = "checkout"   // Taken by the user during checkout
+	HoldReschedule = "reschedule" // Keeps the new time of a reschedule awaiting approval
+	HoldWaitlist   = "waitlist"   // Time offered to a waitlisted user
+)
+
+// BookingHold reserves time of a talent for one user during checkout. Active holds
+// (not released and not expired) make the time unavailable to everyone else.
+// A hold spanning several dates is stored as one row per date sharing a HoldID.
+type BookingHold struct {
+	ID              uint        `gorm:"primaryKey" json:"id"`
+	HoldID          string      `gorm:"size:64;not null;index" json:"hold_id"`
+	Kind            string      `gorm:"size:16;not null;default:'checkout'" json:"kind"` // One of the Hold* kinds
+	UserID          string      `gorm:"size:64;not null;index" json:"user_id"`
+	TalentID        string      `gorm:"size:64;not null;index:idx_booking_holds_talent_date" json:"talent_id"`
+	CardID          string      `json:"card_id"`
+	CardTitle       string      `json:"card_title"`
+	SessionType     SessionType `gorm:"type:text" json:"session_type"`
+	BookingDate     time.Time   `gorm:"index:idx_booking_holds_talent_date" json:"booking_date"`
+	HeldTime        TimeRanges  `gorm:"type:jsonb" json:"held_time"`
+	SpecialRequests string      `json:"special_requests"`
+	ExpiresAt       time.Time   `gorm:"not null;index" json:"expires_at"`
+	ReleasedAt      *time.Time  `json:"released_at,omitempty"` // Set on confirm, cancel or expiry
+	BookingID       string      `json:"booking_id,omitempty"`  // BookingRequests created on confirm
+	CreatedAt       time.Time   `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// Statuses of a RescheduleRequest.
+const (
+	ReschedulePending   = "Pending"
+	RescheduleApproved  = "Approved"
+	RescheduleRejected  = "Rejected"
+	RescheduleWithdrawn = "Withdrawn" // Replaced by a newer request
+	RescheduleExpired   = "Expired"
+)
+
+// RescheduleRequest is a move of a booking that waits for the talent's approval.
+// The new time is kept on hold for the booker until the request is decided.
+type RescheduleRequest struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	BookingID   string     `gorm:"size:64;not null;index" json:"booking_id"`
+	RequestedBy string     `gorm:"size:64;not null" json:"requested_by"`
+	NewDate     time.Time  `json:"new_date"`
+	NewTime     TimeRanges `gorm:"type:jsonb" json:"new_time"`
+	Reason      string     `gorm:"type:text" json:"reason,omitempty"`
+	HoldID      string     `gorm:"size:64" json:"hold_id"` // BookingHold reserving the new time
+	Status      string     `gorm:"size:16;not null" json:"status"`
+	DecidedBy   string     `gorm:"size:64" json:"decided_by,omitempty"`
+	DecidedAt   *time.Time `json:"decided_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// Statuses of a WaitlistEntry.
+const (
+	WaitlistWaiting = "Waiting"
+	WaitlistOffered = "Offered" // A slot is on hold for the user until OfferExpiresAt
+	WaitlistBooked  = "Booked"
+	WaitlistExpired = "Expired" // The offer ran out; the user has to join again
+	WaitlistLeft    = "Left"
+)
+
+// WaitlistEntry puts a user in line for a card of a talent on one date. When time
+// frees up the first waiting user is offered a slot through a BookingHold.
+type WaitlistEntry struct {
+	ID             uint        `gorm:"primaryKey" json:"id"`
+	UserID         string      `gorm:"size:64;not null;index" json:"user_id"`
+	TalentID       string      `gorm:"size:64;not null;index:idx_waitlist_talent_date" json:"talent_id"`
+	CardID         string      `gorm:"size:64;not null" json:"card_id"`
+	SessionType    SessionType `gorm:"type:text" json:"session_type"`
+	WaitDate       time.Time   `gorm:"not null;index:idx_waitlist_talent_date" json:"wait_date"`
+	Status         string      `gorm:"size:16;not null" json:"status"`
+	OfferHoldID    string      `gorm:"size:64;index" json:"offer_hold_id,omitempty"` // BookingHold offered to the user
+	OfferExpiresAt *time.Time  `json:"offer_expires_at,omitempty"`
+	CreatedAt      time.Time   `gorm:"autoCreateTime" json:"created_at"` // Position in line
+	UpdatedAt      time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// BookingStatusHistory records one status change of a BookingRequests row.
+type BookingStatusHistory struct {
+	ID         uint          `gorm:"primaryKey" json:"id"`
+	BookingID  string        `gorm:"size:64;not null;index" json:"booking_id"` // BookingRequests.BookingID
+	FromStatus BookingStatus `gorm:"type:text" json:"from_status"`             // Empty for the initial status
+	ToStatus   BookingStatus `gorm:"type:text;not null" json:"to_status"`
+	ActorID    string        `gorm:"size:64" json:"actor_id"`            // User who made the change; empty for the system
+	ActorRole  string        `gorm:"size:16;not null" json:"actor_role"` // user, talent, admin or system
+	Reason     string        `gorm:"type:text" json:"reason,omitempty"`  // Optional note from the actor
+	CreatedAt  time.Time     `gorm:"autoCreateTime" json:"created_at"`
+}
+
 // Value implements the driver.Valuer interface.
 func (t TimeRanges) Value() (driver.Value, error) {
 	if len(t) == 0 {
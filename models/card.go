@@ -3,6 +3,7 @@ package models
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
@@ -22,33 +23,124 @@ func (s StringSlice) Value() (driver.Value, error) {
 
 // TalentRegistration represents the structure for talent registration data
 type TalentRegistration struct {
-	UserID          string         `gorm:"not nul" json:"user_id"`                   // Primary Keyst
-	TalentID        string         `gorm:"not null" json:"talent_id"`                // Auto-increment Primary Key
-	TalentName      string         `gorm:"size:255;not null" json:"talent_name"`     // Name of the talent
-	Category        string         `gorm:"size:100;not null" json:"category"`        // Talent category
-	Bio             string         `gorm:"type:text;not null" json:"bio"`            // Short bio
-	Skills          string         `gorm:"type:text" json:"skills"`                  // Array of skills
-	PortfolioLink   string         `gorm:"size:255" json:"portfolio_link"`           // Portfolio URL
-	ProfileImageURL string         `gorm:"size:255" json:"profile_image_url"`        // Uploaded image URL
-	ExperienceLevel string         `gorm:"size:50;not null" json:"experience_level"` // Experience Level
-	CreatedAt       time.Time      `gorm:"autoCreateTime" json:"created_at"`         // Timestamp when created
-	UpdatedAt       time.Time      `gorm:"autoUpdateTime" json:"updated_at"`         // Timestamp when updated
-	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`        // Soft delete
+	UserID          string         `gorm:"not nul" json:"user_id"`                       // Primary Keyst
+	TalentID        string         `gorm:"not null" json:"talent_id"`                    // Auto-increment Primary Key
+	TalentName      string         `gorm:"size:255;not null" json:"talent_name"`         // Name of the talent
+	Category        string         `gorm:"size:100;not null" json:"category"`            // Talent category
+	Bio             string         `gorm:"type:text;not null" json:"bio"`                // Short bio
+	Skills          string         `gorm:"type:text" json:"skills"`                      // Array of skills
+	PortfolioLink   string         `gorm:"size:255" json:"portfolio_link"`               // Portfolio URL
+	ProfileImageURL string         `gorm:"size:255" json:"profile_image_url"`            // Uploaded image URL
+	ExperienceLevel string         `gorm:"size:50;not null" json:"experience_level"`     // Experience Level
+	TimeZone        string         `gorm:"size:64;not null;default:''" json:"time_zone"` // IANA zone the schedule is kept in; "" is the server's zone
+	CreatedAt       time.Time      `gorm:"autoCreateTime" json:"created_at"`             // Timestamp when created
+	UpdatedAt       time.Time      `gorm:"autoUpdateTime" json:"updated_at"`             // Timestamp when updated
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`            // Soft delete
 }
 
 // ServiceCard represents the individual service offerings by talents
 type ServiceCard struct {
-	CardID          string         `gorm:"not null;" json:"card_id"`                   // Primary Key
-	TalentID        string         `gorm:"not null;index" json:"talent_id"`            // Foreign Key: Links to Talent table
-	CardTitle       string         `gorm:"size:255;not null" json:"card_title"`        // Title of the service
-	CardDescription string         `gorm:"type:text;not null" json:"card_description"` // Detailed description
-	Suit            string         `gorm:"size:50;not null" json:"suit"`               // Enum: "Heart", "Spade", "Diamond", "Clover"
-	Price           int            `gorm:"not null" json:"price"`                      // Price for the service
-	Duration        int            `gorm:"not null" json:"duration"`                   // Duration in minutes
-	Tags            string         `gorm:"size:255" json:"tags"`                       // Comma-separated tags
-	CreatedAt       time.Time      `gorm:"autoCreateTime" json:"created_at"`           // Timestamp for creation
-	UpdatedAt       time.Time      `gorm:"autoUpdateTime" json:"updated_at"`           // Timestamp for update
-	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`          // Soft delete field
+	CardID              string             `gorm:"not null;" json:"card_id"`                          // Primary Key
+	TalentID            string             `gorm:"not null;index" json:"talent_id"`                   // Foreign Key: Links to Talent table
+	CardTitle           string             `gorm:"size:255;not null" json:"card_title"`               // Title of the service
+	CardDescription     string             `gorm:"type:text;not null" json:"card_description"`        // Detailed description
+	Suit                string             `gorm:"size:50;not null" json:"suit"`                      // Enum: "Heart", "Spade", "Diamond", "Clover"
+	Price               int                `gorm:"not null" json:"price"`                             // Price for the service
+	Duration            int                `gorm:"not null" json:"duration"`                          // Duration in minutes
+	Tags                string             `gorm:"size:255" json:"tags"`                              // Comma-separated tags
+	CancellationPolicy  CancellationPolicy `gorm:"type:jsonb" json:"cancellation_policy"`             // Refund tiers; empty means DefaultCancellationPolicy
+	MaxReschedules      *int               `json:"max_reschedules"`                                   // How often one booking may be moved; nil means DefaultMaxReschedules, 0 disables rescheduling
+	RescheduleApproval  bool               `gorm:"not null;default:false" json:"reschedule_approval"` // Reschedules by the booker wait for the talent's approval
+	Capacity            int                `gorm:"not null;default:1" json:"capacity"`                // Users per session; above 1 makes it a group session
+	BufferBeforeMinutes *int               `json:"buffer_before_minutes"`                             // Overrides TalentSchedulingRules when set
+	BufferAfterMinutes  *int               `json:"buffer_after_minutes"`                              // Overrides TalentSchedulingRules when set
+	MinNoticeMinutes    *int               `json:"min_notice_minutes"`                                // Overrides TalentSchedulingRules when set
+	MaxDaysInAdvance    *int               `json:"max_days_in_advance"`                               // Overrides TalentSchedulingRules when set
+	CreatedAt           time.Time          `gorm:"autoCreateTime" json:"created_at"`                  // Timestamp for creation
+	UpdatedAt           time.Time          `gorm:"autoUpdateTime" json:"updated_at"`                  // Timestamp for update
+	DeletedAt           gorm.DeletedAt     `gorm:"index" json:"deleted_at,omitempty"`                 // Soft delete field
+}
+
+// TalentSchedulingRules holds a talent's booking rules. Each ServiceCard can
+// override single values; talents without a row use the defaults of the booking
+// package.
+type TalentSchedulingRules struct {
+	ID                  uint      `gorm:"primaryKey" json:"-"`
+	TalentID            string    `gorm:"size:64;not null;uniqueIndex" json:"talent_id"`
+	BufferBeforeMinutes int       `gorm:"not null;default:0" json:"buffer_before_minutes"` // Free time kept before each session
+	BufferAfterMinutes  int       `gorm:"not null;default:0" json:"buffer_after_minutes"`  // Free time kept after each session
+	MinNoticeMinutes    int       `gorm:"not null" json:"min_notice_minutes"`              // How long before its start a slot can still be booked
+	MaxDaysInAdvance    int       `gorm:"not null" json:"max_days_in_advance"`             // How many days ahead slots can be booked
+	UpdatedAt           time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// DefaultMaxReschedules applies to cards that do not set MaxReschedules.
+const DefaultMaxReschedules = 2
+
+// RescheduleLimit returns how often one booking of the card may be moved.
+func (c ServiceCard) RescheduleLimit() int {
+	if c.MaxReschedules == nil {
+		return DefaultMaxReschedules
+	}
+	return *c.MaxReschedules
+}
+
+// CancellationRule refunds RefundPercent of the price when a booking is cancelled
+// by its user at least MinHoursBefore hours before the session starts.
+type CancellationRule struct {
+	MinHoursBefore int `json:"min_hours_before"`
+	RefundPercent  int `json:"refund_percent"` // 0-100
+}
+
+// CancellationPolicy is the list of refund tiers of a ServiceCard. The tier with
+// the largest MinHoursBefore that is still met applies; after the session has
+// started nothing is refunded.
+type CancellationPolicy []CancellationRule
+
+// DefaultCancellationPolicy applies to cards without their own policy: a full
+// refund more than 24 hours ahead, half within the last 24 hours.
+var DefaultCancellationPolicy = CancellationPolicy{
+	{MinHoursBefore: 24, RefundPercent: 100},
+	{MinHoursBefore: 0, RefundPercent: 50},
+}
+
+// Value implements the driver.Valuer interface.
+func (p CancellationPolicy) Value() (driver.Value, error) {
+	if len(p) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(p)
+}
+
+// Scan implements the sql.Scanner interface.
+func (p *CancellationPolicy) Scan(value interface{}) error {
+	if value == nil {
+		*p = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal cancellation policy: %v", value)
+	}
+	return json.Unmarshal(bytes, p)
+}
+
+// Validate checks that every tier is within range and no two tiers share a threshold.
+func (p CancellationPolicy) Validate() error {
+	seen := make(map[int]bool)
+	for _, rule := range p {
+		if rule.MinHoursBefore < 0 {
+			return fmt.Errorf("min_hours_before must not be negative")
+		}
+		if rule.RefundPercent < 0 || rule.RefundPercent > 100 {
+			return fmt.Errorf("refund_percent must be between 0 and 100")
+		}
+		if seen[rule.MinHoursBefore] {
+			return fmt.Errorf("duplicate cancellation tier for %d hours", rule.MinHoursBefore)
+		}
+		seen[rule.MinHoursBefore] = true
+	}
+	return nil
 }
 
 // Booking represents session bookings for CoffeeCalls and other services
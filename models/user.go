@@ -6,6 +6,17 @@ import (
 	"gorm.io/gorm"
 )
 
+// Account types stored in Users_ref.AccountType.
+const (
+	AccountTypeUser   = "User"
+	AccountTypeTalent = "Talent"
+	AccountTypeAdmin  = "Admin"
+)
+
+// User is the legacy account model behind /api/signup and /api/login.
+//
+// Deprecated: accounts live in Users_ref. The users table is only kept so that
+// config.migrateLegacyUsers can merge its rows into users_refs on startup.
 type User struct {
 	ID       string `json:"id" gorm:"primaryKey"` // UUID as primary key
 	Email    string `json:"email" gorm:"unique"`
@@ -14,14 +25,21 @@ type User struct {
 
 // Users represents the user model for registration and management.
 type Users_ref struct {
-	UserID      string         `gorm:"primaryKey;not null" json:"user_id"`                  // Primary Key
-	FirstName   string         `gorm:"size:100;not null" json:"first_name"`                 // User's first name
-	LastName    string         `gorm:"size:100;not null" json:"last_name"`                  // User's last name
-	Phone       string         `gorm:"size:20;unique;not null" json:"phone"`                // User's phone number
-	Email       string         `gorm:"size:100;unique;not null" json:"email"`               // User's email address
-	Password    string         `gorm:"size:255;not null" json:"password"`                   // Hashed password
-	AccountType string         `gorm:"size:50;not null;default:'User'" json:"account_type"` // Enum: "User" or "Talent"
-	CreatedAt   time.Time      `gorm:"autoCreateTime" json:"created_at"`                    // User creation timestamp
-	UpdatedAt   time.Time      `gorm:"autoUpdateTime" json:"updated_at"`                    // User profile last updated timestamp
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`                   // Soft delete
+	UserID          string         `gorm:"primaryKey;not null" json:"user_id"`                  // Primary Key
+	FirstName       string         `gorm:"size:100;not null" json:"first_name"`                 // User's first name
+	LastName        string         `gorm:"size:100;not null" json:"last_name"`                  // User's last name
+	Phone           *string        `gorm:"size:20;unique" json:"phone"`                         // User's phone number, empty for accounts created through /api/signup
+	Email           string         `gorm:"size:100;unique;not null" json:"email"`               // User's email address
+	Password        string         `gorm:"size:255;not null" json:"password"`                   // Hashed password
+	EmailVerifiedAt *time.Time     `json:"email_verified_at,omitempty"`                         // Set once the email address has been confirmed
+	PhoneVerifiedAt *time.Time     `json:"phone_verified_at,omitempty"`                         // Set once the phone number has been confirmed
+	TOTPSecret      string         `gorm:"size:64" json:"-"`                                    // Base32 TOTP secret, set during enrollment
+	TOTPEnabledAt   *time.Time     `json:"totp_enabled_at,omitempty"`                           // Set once enrollment has been confirmed
+	TOTPLastStep    int64          `gorm:"not null;default:0" json:"-"`                         // Last accepted TOTP time step, blocks code replay
+	LegacyUserID    *string        `gorm:"size:64;unique" json:"-"`                             // ID of the merged legacy users row, if any
+	AccountType     string         `gorm:"size:50;not null;default:'User'" json:"account_type"` // Enum: "User", "Talent" or "Admin"
+	TimeZone        string         `gorm:"size:64;not null;default:''" json:"time_zone"`        // IANA zone times are shown in; "" follows the talent
+	CreatedAt       time.Time      `gorm:"autoCreateTime" json:"created_at"`                    // User creation timestamp
+	UpdatedAt       time.Time      `gorm:"autoUpdateTime" json:"updated_at"`                    // User profile last updated timestamp
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`                   // Soft delete
 }
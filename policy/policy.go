@@ -0,0 +1,96 @@
+package policy
+
+import (
+	"log"
+	"net/http"
+
+	"taas-api/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Action is an operation guarded by the role policy.
+type Action string
+
+const (
+	RegisterTalent      Action = "talents:register"
+	ManageServiceCards  Action = "cards:write"
+	PublishAvailability Action = "availability:write"
+	AcceptBookings      Action = "bookings:accept"
+	ManageAPIKeys       Action = "api_keys:write"
+	AdminOperations     Action = "admin"
+)
+
+// Scopes that can be granted to API keys. Sessions signed in with a password
+// implicitly hold every scope.
+const (
+	ScopeBookingsRead  = "bookings:read"
+	ScopeScheduleWrite = "schedule:write"
+	ScopeCardsWrite    = "cards:write"
+)
+
+// APIKeyScopes lists every scope an API key may be granted.
+var APIKeyScopes = []string{ScopeBookingsRead, ScopeScheduleWrite, ScopeCardsWrite}
+
+// permissions lists the account types allowed to perform each action.
+var permissions = map[Action][]string{
+	RegisterTalent:      {models.AccountTypeTalent, models.AccountTypeAdmin},
+	ManageServiceCards:  {models.AccountTypeTalent, models.AccountTypeAdmin},
+	PublishAvailability: {models.AccountTypeTalent, models.AccountTypeAdmin},
+	AcceptBookings:      {models.AccountTypeTalent, models.AccountTypeAdmin},
+	ManageAPIKeys:       {models.AccountTypeTalent, models.AccountTypeAdmin},
+	AdminOperations:     {models.AccountTypeAdmin},
+}
+
+// Can reports whether an account type is allowed to perform the action.
+func Can(accountType string, action Action) bool {
+	for _, allowed := range permissions[action] {
+		if allowed == accountType {
+			return true
+		}
+	}
+	return false
+}
+
+// Forbid aborts the request with the 403 body shared by every authorization failure.
+func Forbid(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"error": message,
+		"code":  "forbidden",
+	})
+}
+
+// OwnsTalent reports whether the talent registration belongs to the given user.
+func OwnsTalent(db *gorm.DB, userID string, talentID string) (bool, error) {
+	if userID == "" || talentID == "" {
+		return false, nil
+	}
+	var count int64
+	if err := db.Model(&models.TalentRegistration{}).
+		Where("talent_id = ? AND user_id = ?", talentID, userID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// AuthorizeTalent checks that the caller may act on behalf of talentID: admins may
+// act on any talent, everyone else only on talent registrations they own. On
+// failure the response is written and false is returned.
+func AuthorizeTalent(c *gin.Context, db *gorm.DB, userID, accountType, talentID string) bool {
+	if accountType == models.AccountTypeAdmin {
+		return true
+	}
+	owns, err := OwnsTalent(db, userID, talentID)
+	if err != nil {
+		log.Printf("Error verifying ownership of talent %s for user %s: %v\n", talentID, userID, err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify talent ownership"})
+		return false
+	}
+	if !owns {
+		Forbid(c, "You do not own this talent account")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Config tunes the login limiter. Zero values are replaced by DefaultConfig.
+type Config struct {
+	Window           time.Duration // Sliding window in which failures are counted
+	FreeAttempts     int           // Account failures allowed before delays start
+	BaseDelay        time.Duration // First progressive delay; doubles with each further failure
+	MaxDelay         time.Duration // Upper bound of the progressive delay
+	AccountLockAfter int           // Account failures in the window that trigger a lockout
+	IPLockAfter      int           // Failures from one IP in the window that trigger a lockout
+	LockoutDuration  time.Duration // How long a lockout lasts
+	Prefix           string        // Prepended to every key, so limiters can share a store
+}
+
+// DefaultConfig is used for any zero field of Config.
+var DefaultConfig = Config{
+	Window:           15 * time.Minute,
+	FreeAttempts:     3,
+	BaseDelay:        time.Second,
+	MaxDelay:         8 * time.Second,
+	AccountLockAfter: 10,
+	IPLockAfter:      50,
+	LockoutDuration:  15 * time.Minute,
+}
+
+// Decision tells the caller how to treat a sign-in attempt.
+type Decision struct {
+	Locked     bool          // The attempt must be rejected
+	RetryAfter time.Duration // Time until the lockout ends, when Locked
+	Delay      time.Duration // Progressive delay to apply before checking credentials
+}
+
+// Limiter applies per-IP and per-account sliding-window limits to sign-in attempts.
+type Limiter struct {
+	store Store
+	cfg   Config
+	now   func() time.Time
+}
+
+// NewLimiter returns a limiter that keeps its state in store.
+func NewLimiter(store Store, cfg Config) *Limiter {
+	if cfg.Window == 0 {
+		cfg.Window = DefaultConfig.Window
+	}
+	if cfg.FreeAttempts == 0 {
+		cfg.FreeAttempts = DefaultConfig.FreeAttempts
+	}
+	if cfg.BaseDelay == 0 {
+		cfg.BaseDelay = DefaultConfig.BaseDelay
+	}
+	if cfg.MaxDelay == 0 {
+		cfg.MaxDelay = DefaultConfig.MaxDelay
+	}
+	if cfg.AccountLockAfter == 0 {
+		cfg.AccountLockAfter = DefaultConfig.AccountLockAfter
+	}
+	if cfg.IPLockAfter == 0 {
+		cfg.IPLockAfter = DefaultConfig.IPLockAfter
+	}
+	if cfg.LockoutDuration == 0 {
+		cfg.LockoutDuration = DefaultConfig.LockoutDuration
+	}
+	return &Limiter{store: store, cfg: cfg, now: time.Now}
+}
+
+// Check decides whether an attempt from ip for account may proceed, and how long
+// it should be delayed first.
+func (l *Limiter) Check(ctx context.Context, ip, account string) (Decision, error) {
+	now := l.now()
+	for _, key := range []string{l.ipKey(ip), l.accountKey(account)} {
+		until, err := l.store.LockedUntil(ctx, key, now)
+		if err != nil {
+			return Decision{}, err
+		}
+		if !until.IsZero() {
+			return Decision{Locked: true, RetryAfter: until.Sub(now)}, nil
+		}
+	}
+
+	failures, err := l.store.Failures(ctx, l.accountKey(account), now, l.cfg.Window)
+	if err != nil {
+		return Decision{}, err
+	}
+	return Decision{Delay: l.delayFor(failures)}, nil
+}
+
+// Fail records a failed attempt and locks the IP or account once its threshold
+// is reached. The returned decision is Locked when this failure caused a lockout.
+func (l *Limiter) Fail(ctx context.Context, ip, account string) (Decision, error) {
+	now := l.now()
+	until := now.Add(l.cfg.LockoutDuration)
+	decision := Decision{}
+
+	accountFailures, err := l.store.RecordFailure(ctx, l.accountKey(account), now, l.cfg.Window)
+	if err != nil {
+		return Decision{}, err
+	}
+	if accountFailures >= l.cfg.AccountLockAfter {
+		if err := l.store.Lock(ctx, l.accountKey(account), until); err != nil {
+			return Decision{}, err
+		}
+		decision = Decision{Locked: true, RetryAfter: l.cfg.LockoutDuration}
+	}
+
+	ipFailures, err := l.store.RecordFailure(ctx, l.ipKey(ip), now, l.cfg.Window)
+	if err != nil {
+		return Decision{}, err
+	}
+	if ipFailures >= l.cfg.IPLockAfter {
+		if err := l.store.Lock(ctx, l.ipKey(ip), until); err != nil {
+			return Decision{}, err
+		}
+		decision = Decision{Locked: true, RetryAfter: l.cfg.LockoutDuration}
+	}
+	return decision, nil
+}
+
+// Succeed clears the failure history of the account after a successful sign-in.
+// IP counters are kept so that one valid account cannot launder a stuffing run.
+func (l *Limiter) Succeed(ctx context.Context, account string) error {
+	return l.store.Reset(ctx, l.accountKey(account))
+}
+
+// delayFor returns the progressive delay after the given number of failures.
+func (l *Limiter) delayFor(failures int) time.Duration {
+	if failures < l.cfg.FreeAttempts {
+		return 0
+	}
+	delay := l.cfg.BaseDelay
+	for i := l.cfg.FreeAttempts; i < failures && delay < l.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > l.cfg.MaxDelay {
+		delay = l.cfg.MaxDelay
+	}
+	return delay
+}
+
+func (l *Limiter) ipKey(ip string) string {
+	return l.cfg.Prefix + "ip:" + ip
+}
+
+func (l *Limiter) accountKey(account string) string {
+	return l.cfg.Prefix + "account:" + account
+}
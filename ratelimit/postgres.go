@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"taas-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresStore is a Store backed by the rate_limit_hits and rate_limit_locks
+// tables, so that every API instance shares the same counters.
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresStore returns a store using db. The tables are created by AutoMigrate.
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// RecordFailure implements Store.
+func (s *PostgresStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	db := s.db.WithContext(ctx)
+	if err := db.Create(&models.RateLimitHit{Key: key, CreatedAt: now}).Error; err != nil {
+		return 0, err
+	}
+	// Keep the table small: hits outside the window are never counted again
+	if err := db.Where("key = ? AND created_at <= ?", key, now.Add(-window)).Delete(&models.RateLimitHit{}).Error; err != nil {
+		return 0, err
+	}
+	return s.Failures(ctx, key, now, window)
+}
+
+// Failures implements Store.
+func (s *PostgresStore) Failures(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.RateLimitHit{}).
+		Where("key = ? AND created_at > ?", key, now.Add(-window)).
+		Count(&count).Error
+	return int(count), err
+}
+
+// Reset implements Store.
+func (s *PostgresStore) Reset(ctx context.Context, key string) error {
+	db := s.db.WithContext(ctx)
+	if err := db.Where("key = ?", key).Delete(&models.RateLimitHit{}).Error; err != nil {
+		return err
+	}
+	return db.Where("key = ?", key).Delete(&models.RateLimitLock{}).Error
+}
+
+// Lock implements Store.
+func (s *PostgresStore) Lock(ctx context.Context, key string, until time.Time) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"locked_until"}),
+	}).Create(&models.RateLimitLock{Key: key, LockedUntil: until}).Error
+}
+
+// LockedUntil implements Store.
+func (s *PostgresStore) LockedUntil(ctx context.Context, key string, now time.Time) (time.Time, error) {
+	var lock models.RateLimitLock
+	err := s.db.WithContext(ctx).Where("key = ? AND locked_until > ?", key, now).First(&lock).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return lock.LockedUntil, nil
+}
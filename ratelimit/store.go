@@ -0,0 +1,22 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Store keeps failure counters and lockouts for rate-limit keys such as
+// "ip:203.0.113.7" or "account:jane@example.com".
+type Store interface {
+	// RecordFailure adds a failure for key at now and returns the number of
+	// failures recorded within the sliding window ending at now.
+	RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (int, error)
+	// Failures returns the number of failures within the sliding window ending at now.
+	Failures(ctx context.Context, key string, now time.Time, window time.Duration) (int, error)
+	// Reset forgets all failures of key.
+	Reset(ctx context.Context, key string) error
+	// Lock blocks key until the given time.
+	Lock(ctx context.Context, key string, until time.Time) error
+	// LockedUntil returns the end of the active lockout of key, or the zero time.
+	LockedUntil(ctx context.Context, key string, now time.Time) (time.Time, error)
+}
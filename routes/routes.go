@@ -2,6 +2,9 @@ package routes
 
 import (
 	"taas-api/handlers"
+	"taas-api/middleware"
+	"taas-api/policy"
+	"taas-api/utils"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -15,62 +18,122 @@ func SetupRoutes() *gin.Engine {
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"}, // Allow all origins, change to specific origins as needed
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,           // Allow cookies and credentials
 		MaxAge:           12 * time.Hour, // Cache preflight requests for 12 hours
 	}))
 
+	// Routes below this group require a valid access token
+	auth := router.Group("/")
+	auth.Use(middleware.AuthRequired())
+
 	// Authentication User routes
 	router.POST("/api/signup", handlers.Signup)
 	router.POST("/api/login", handlers.Login)
 
 	router.POST("/api/register", handlers.RegisterUser)
 	router.POST("/api/signin", handlers.SignIn)
-	router.POST("/api/register-talent", handlers.RegisterTalent)
+	router.POST("/api/token/refresh", handlers.RefreshSession)
+	router.POST("/api/logout", handlers.Logout)
+	auth.POST("/api/logout-all", handlers.LogoutAll)
+	router.POST("/api/password/forgot", handlers.ForgotPassword)
+	router.POST("/api/password/reset", handlers.ResetPassword)
+	auth.POST("/api/verify/:channel/send", handlers.SendVerificationCode)
+	auth.POST("/api/verify/:channel/confirm", handlers.ConfirmVerificationCode)
+
+	// Two-factor authentication routes
+	router.POST("/api/signin/mfa", handlers.VerifyMFASignIn)
+	mfaEnroll := router.Group("/api/mfa/totp")
+	mfaEnroll.Use(middleware.AuthRequiredAllowing(utils.PurposeMFAEnroll))
+	mfaEnroll.POST("/enroll", handlers.EnrollTOTP)
+	mfaEnroll.POST("/confirm", handlers.ConfirmTOTP)
+	auth.POST("/api/mfa/totp/disable", handlers.DisableTOTP)
+
+	// API keys for server-to-server integrations; managing them needs a user session
+	auth.POST("/api/api-keys", middleware.Require(policy.ManageAPIKeys), handlers.CreateAPIKey)
+	auth.GET("/api/api-keys", middleware.Require(policy.ManageAPIKeys), handlers.ListAPIKeys)
+	auth.DELETE("/api/api-keys/:id", middleware.Require(policy.ManageAPIKeys), handlers.RevokeAPIKey)
+
+	auth.POST("/api/register-talent", middleware.Require(policy.RegisterTalent), handlers.RegisterTalent)
 	router.GET("/api/get-talents", handlers.GetTalentAccounts)
 
 	// Card routes
-	router.POST("/api/cards", handlers.SaveCard)
-	router.POST("/api/generate-cards", handlers.CreateServiceCard)
+	auth.POST("/api/cards", handlers.SaveCard)
+	router.POST("/api/generate-cards", middleware.AuthWithScope(policy.ScopeCardsWrite), middleware.Require(policy.ManageServiceCards), handlers.CreateServiceCard)
 
 	router.GET("/api/get-cards", handlers.GetCardsByTalentID)
-	router.GET("/api/cards/user", handlers.GetUserCards)
+	auth.GET("/api/cards/user", handlers.GetUserCards)
 
 	//Card routes for editiong and deleting
-	router.PATCH("api/cards/edit-card/:cardId", handlers.EditCard)
-	router.DELETE("api/cards/delete-card/:cardId", handlers.DeleteCard)
+	router.PATCH("api/cards/edit-card/:cardId", middleware.AuthWithScope(policy.ScopeCardsWrite), middleware.Require(policy.ManageServiceCards), handlers.EditCard)
+	router.DELETE("api/cards/delete-card/:cardId", middleware.AuthWithScope(policy.ScopeCardsWrite), middleware.Require(policy.ManageServiceCards), handlers.DeleteCard)
 
 	// Card routes for getting all the cards
 	router.GET("/api/cards/all", handlers.GetAllCards)
 	router.GET("/api/cards/event-id", handlers.Cards_Id)
 
 	//Video Control routes
-	router.POST("/api/save-video-control", handlers.SaveVideoControl)
+	auth.POST("/api/save-video-control", handlers.SaveVideoControl)
 	router.GET("/api/get-video-control", handlers.GetVideoControl)
-	router.POST("/api/update-video-control", handlers.UpdateVideoControl)
+	auth.POST("/api/update-video-control", handlers.UpdateVideoControl)
 
 	//Booking routes
-	router.GET("/api/bookings/user/:user_id", handlers.GetBookingsByUser)
-	router.GET("/api/bookings/talent/:talent_id", handlers.GetBookingsByTalent)
-	router.POST("/api/book-cards", handlers.CreateBookings)
-
-	router.GET("/api/bookingRequest", handlers.RetrieveMyBookedCardsRequestToTalent)
-	router.PATCH("/api/handle-bookingStatus", handlers.HandleUpdateBookingStatus)
+	router.GET("/api/bookings/user/:user_id", middleware.AuthWithScope(policy.ScopeBookingsRead), handlers.GetBookingsByUser)
+	router.GET("/api/bookings/talent/:talent_id", middleware.AuthWithScope(policy.ScopeBookingsRead), handlers.GetBookingsByTalent)
+	auth.POST("/api/book-cards", handlers.CreateBookings)
+	auth.PATCH("/api/bookings/:id/status", handlers.UpdateBookingRequestStatus)
+	auth.GET("/api/bookings/:id/history", handlers.GetBookingStatusHistory)
+	auth.POST("/api/bookings/:id/cancel", handlers.CancelBooking)
+	auth.POST("/api/bookings/:id/reschedule", handlers.RescheduleBooking)
+	auth.POST("/api/bookings/:id/reschedule/approve", handlers.ApproveReschedule)
+	auth.POST("/api/bookings/:id/reschedule/reject", handlers.RejectReschedule)
+	auth.POST("/api/booking-series", handlers.CreateBookingSeries)
+	auth.GET("/api/booking-series/:id", handlers.GetBookingSeries)
+
+	// Checkout holds keep slots reserved for a few minutes until the booking is confirmed
+	auth.POST("/api/booking-holds", handlers.CreateBookingHold)
+	auth.POST("/api/booking-holds/:id/confirm", handlers.ConfirmBookingHold)
+	auth.DELETE("/api/booking-holds/:id", handlers.ReleaseBookingHold)
+
+	// Waitlist for fully booked dates
+	auth.POST("/api/waitlist", handlers.JoinWaitlist)
+	auth.GET("/api/waitlist", handlers.ListMyWaitlist)
+	auth.DELETE("/api/waitlist/:id", handlers.LeaveWaitlist)
+
+	auth.GET("/api/bookingRequest", handlers.RetrieveMyBookedCardsRequestToTalent)
+	auth.PATCH("/api/handle-bookingStatus", middleware.Require(policy.AcceptBookings), handlers.HandleUpdateBookingStatus)
 
 	//Notification route
 	router.GET("/api/notifications", handlers.HandleNotificationStream)
 
 	//Static files route
-	router.POST("/api/upload", handlers.FileUploadHandler)
+	auth.POST("/api/upload", handlers.FileUploadHandler)
 
 	//Schedule management routes
-	router.POST("/api/create-schedule", handlers.CreateAvailableSlots)
+	router.POST("/api/create-schedule", middleware.AuthWithScope(policy.ScopeScheduleWrite), middleware.Require(policy.PublishAvailability), handlers.CreateAvailableSlots)
 	router.GET("/api/get-all-raw-schedule", handlers.FetchrawAllAvailableTimeSlots)
-	//router.GET("/api/get-all-filtered_schedule", handlers.FetchFilteredAvailableTimeSlots)
-	router.GET("/api/get-all-filtered_schedule", handlers.FetchBookFilteredAvailableTimeSlots)
-
-	// router.PATCH("/api/update-schedule", handlers.UpdateAvailableSlots)
-	// router.DELETE("/api/delete-schedule", handlers.DeleteAvailableSlot)
+	router.GET("/api/get-all-filtered_schedule", middleware.AuthOptional(), handlers.FetchBookFilteredAvailableTimeSlots)
+	router.GET("/api/search/availability", middleware.AuthOptional(), handlers.SearchAvailability)
+	router.GET("/api/talents/:talent_id/scheduling-rules", handlers.GetSchedulingRules)
+	auth.PUT("/api/talents/:talent_id/scheduling-rules", middleware.Require(policy.PublishAvailability), handlers.UpdateSchedulingRules)
+	auth.PUT("/api/talents/:talent_id/time-zone", middleware.Require(policy.PublishAvailability), handlers.UpdateTalentTimeZone)
+	auth.PUT("/api/me/time-zone", handlers.UpdateMyTimeZone)
+	router.GET("/api/talents/:talent_id/availability-templates", handlers.ListAvailabilityTemplates)
+	auth.POST("/api/talents/:talent_id/availability-templates", middleware.Require(policy.PublishAvailability), handlers.CreateAvailabilityTemplate)
+	auth.DELETE("/api/talents/:talent_id/availability-templates/:id", middleware.Require(policy.PublishAvailability), handlers.DeleteAvailabilityTemplate)
+	router.GET("/api/talents/:talent_id/availability-exceptions", handlers.ListAvailabilityExceptions)
+	auth.PUT("/api/talents/:talent_id/availability-exceptions/:date", middleware.Require(policy.PublishAvailability), handlers.PutAvailabilityException)
+	auth.DELETE("/api/talents/:talent_id/availability-exceptions/:date", middleware.Require(policy.PublishAvailability), handlers.DeleteAvailabilityException)
+
+	router.PATCH("/api/update-schedule", middleware.AuthWithScope(policy.ScopeScheduleWrite), middleware.Require(policy.PublishAvailability), handlers.UpdateAvailableSlots)
+	router.DELETE("/api/delete-schedule", middleware.AuthWithScope(policy.ScopeScheduleWrite), middleware.Require(policy.PublishAvailability), handlers.DeleteAvailableSlot)
+
+	// Admin routes
+	admin := auth.Group("/api/admin")
+	admin.Use(middleware.Require(policy.AdminOperations))
+	admin.PATCH("/users/:user_id/account-type", handlers.UpdateAccountType)
+	admin.GET("/settings/mfa", handlers.GetMFASettings)
+	admin.PUT("/settings/mfa", handlers.UpdateMFASettings)
 	return router
 }
@@ -0,0 +1,113 @@
+// Package scheduling is the pure time arithmetic behind availability and
+// bookings: "HH:MM" clock times, half-open intervals of minutes within a day,
+// sets of them and tiling them into bookable slots. It knows nothing about the
+// database, time zones or calendar dates; callers place the minutes on a day.
+package scheduling
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DayMinutes is the length of a day on the wall clock, and the largest minute
+// an interval may end at ("24:00").
+const DayMinutes = 24 * 60
+
+var (
+	// ErrInvalidClock is returned for times that are not "HH:MM" within a day.
+	ErrInvalidClock = errors.New("invalid clock time")
+	// ErrEmptyInterval is returned for intervals that do not end after they start.
+	ErrEmptyInterval = errors.New("interval must end after it starts")
+)
+
+// ParseClock converts "HH:MM" (or "H:MM") to minutes after midnight. "24:00" is
+// accepted as the end of the day.
+func ParseClock(s string) (int, error) {
+	hours, minutes, ok := strings.Cut(s, ":")
+	if !ok || len(hours) < 1 || len(hours) > 2 || len(minutes) != 2 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidClock, s)
+	}
+	if strings.Trim(hours+minutes, "0123456789") != "" {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidClock, s)
+	}
+	h, _ := strconv.Atoi(hours)
+	m, _ := strconv.Atoi(minutes)
+	if m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidClock, s)
+	}
+	return h*60 + m, nil
+}
+
+// FormatClock formats minutes after midnight as "HH:MM"; DayMinutes is "24:00".
+func FormatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// Interval is the half-open span [Start, End) of minutes after midnight.
+// Intervals that only touch, like 10:00-11:00 and 11:00-12:00, do not overlap.
+type Interval struct {
+	Start int
+	End   int
+}
+
+// Parse builds an interval from "HH:MM" start and end times. It fails unless the
+// interval ends after it starts.
+func Parse(start, end string) (Interval, error) {
+	s, err := ParseClock(start)
+	if err != nil {
+		return Interval{}, err
+	}
+	e, err := ParseClock(end)
+	if err != nil {
+		return Interval{}, err
+	}
+	if e <= s {
+		return Interval{}, fmt.Errorf("%w: %s-%s", ErrEmptyInterval, start, end)
+	}
+	return Interval{Start: s, End: e}, nil
+}
+
+// ParseRange parses "HH:MM-HH:MM" as Parse does.
+func ParseRange(s string) (Interval, error) {
+	start, end, ok := strings.Cut(s, "-")
+	if !ok {
+		return Interval{}, fmt.Errorf("%w: %q", ErrInvalidClock, s)
+	}
+	return Parse(start, end)
+}
+
+// String formats the interval as "HH:MM-HH:MM".
+func (i Interval) String() string {
+	return FormatClock(i.Start) + "-" + FormatClock(i.End)
+}
+
+// Len is the length of the interval in minutes.
+func (i Interval) Len() int {
+	if i.End < i.Start {
+		return 0
+	}
+	return i.End - i.Start
+}
+
+// Empty reports whether the interval covers no time.
+func (i Interval) Empty() bool {
+	return i.End <= i.Start
+}
+
+// Overlaps reports whether the intervals share any time.
+func (i Interval) Overlaps(o Interval) bool {
+	return !i.Empty() && !o.Empty() && i.Start < o.End && o.Start < i.End
+}
+
+// Contains reports whether o lies entirely inside i.
+func (i Interval) Contains(o Interval) bool {
+	return i.Start <= o.Start && o.End <= i.End
+}
+
+// Widen extends the interval by before minutes at the start and after minutes
+// at the end, e.g. by a session's buffers. The result may leave the day.
+func (i Interval) Widen(before, after int) Interval {
+	return Interval{Start: i.Start - before, End: i.End + after}
+}
@@ -0,0 +1,86 @@
+package scheduling
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		err  bool
+	}{
+		{in: "00:00", want: 0},
+		{in: "9:30", want: 570},
+		{in: "23:59", want: 1439},
+		{in: "24:00", want: DayMinutes},
+		{in: "24:01", err: true},
+		{in: "12:60", err: true},
+		{in: "123:00", err: true},
+		{in: "12:0", err: true},
+		{in: "-1:00", err: true},
+		{in: "ab:cd", err: true},
+		{in: "1200", err: true},
+		{in: "", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseClock(tt.in)
+		if tt.err {
+			if !errors.Is(err, ErrInvalidClock) {
+				t.Errorf("ParseClock(%q) error = %v, want ErrInvalidClock", tt.in, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseClock(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestFormatClockRoundTrip(t *testing.T) {
+	for minute := 0; minute <= DayMinutes; minute++ {
+		if got, err := ParseClock(FormatClock(minute)); err != nil || got != minute {
+			t.Fatalf("ParseClock(FormatClock(%d)) = %d, %v", minute, got, err)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	got, err := ParseRange("09:00-10:30")
+	if err != nil || got != (Interval{Start: 540, End: 630}) {
+		t.Fatalf("ParseRange = %v, %v", got, err)
+	}
+	if got.String() != "09:00-10:30" {
+		t.Errorf("String = %q", got.String())
+	}
+	for _, in := range []string{"10:00-10:00", "11:00-10:00"} {
+		if _, err := ParseRange(in); !errors.Is(err, ErrEmptyInterval) {
+			t.Errorf("ParseRange(%q) error = %v, want ErrEmptyInterval", in, err)
+		}
+	}
+	if _, err := ParseRange("10:00"); !errors.Is(err, ErrInvalidClock) {
+		t.Errorf("ParseRange without end: error = %v, want ErrInvalidClock", err)
+	}
+}
+
+func TestIntervalOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b Interval
+		want bool
+	}{
+		{Interval{600, 660}, Interval{660, 720}, false}, // Touching is not overlapping
+		{Interval{600, 660}, Interval{659, 720}, true},
+		{Interval{600, 720}, Interval{630, 640}, true},
+		{Interval{600, 660}, Interval{630, 630}, false}, // Empty intervals overlap nothing
+		{Interval{600, 660}, Interval{700, 800}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Overlaps(tt.b); got != tt.want {
+			t.Errorf("%v.Overlaps(%v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := tt.b.Overlaps(tt.a); got != tt.want {
+			t.Errorf("%v.Overlaps(%v) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
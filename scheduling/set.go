@@ -0,0 +1,106 @@
+package scheduling
+
+import "sort"
+
+// Set is a normalized set of intervals: sorted, non-empty, and neither
+// overlapping nor touching. Build one with Normalize; the set operations return
+// normalized sets.
+type Set []Interval
+
+// Normalize sorts the intervals, drops empty ones and merges those that overlap
+// or touch.
+func Normalize(intervals []Interval) Set {
+	sorted := make([]Interval, 0, len(intervals))
+	for _, i := range intervals {
+		if !i.Empty() {
+			sorted = append(sorted, i)
+		}
+	}
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a].Start < sorted[b].Start })
+
+	set := Set{}
+	for _, i := range sorted {
+		if n := len(set); n > 0 && i.Start <= set[n-1].End {
+			if i.End > set[n-1].End {
+				set[n-1].End = i.End
+			}
+			continue
+		}
+		set = append(set, i)
+	}
+	return set
+}
+
+// Union returns the time in a or b.
+func Union(a, b Set) Set {
+	return Normalize(append(append([]Interval{}, a...), b...))
+}
+
+// Subtract returns the time in a but not in b.
+func Subtract(a, b Set) Set {
+	left := append([]Interval{}, Normalize(a)...)
+	for _, r := range Normalize(b) {
+		var next []Interval
+		for _, i := range left {
+			if !i.Overlaps(r) {
+				next = append(next, i)
+				continue
+			}
+			if i.Start < r.Start {
+				next = append(next, Interval{Start: i.Start, End: r.Start})
+			}
+			if r.End < i.End {
+				next = append(next, Interval{Start: r.End, End: i.End})
+			}
+		}
+		left = next
+	}
+	return Normalize(left)
+}
+
+// Intersect returns the time in both a and b.
+func Intersect(a, b Set) Set {
+	a, b = Normalize(a), Normalize(b)
+	var both []Interval
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		start, end := max(a[i].Start, b[j].Start), min(a[i].End, b[j].End)
+		if start < end {
+			both = append(both, Interval{Start: start, End: end})
+		}
+		if a[i].End < b[j].End {
+			i++
+		} else {
+			j++
+		}
+	}
+	return Normalize(both)
+}
+
+// Covers reports whether i lies entirely inside one interval of the set.
+func (s Set) Covers(i Interval) bool {
+	for _, r := range s {
+		if r.Contains(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// Overlaps reports whether i shares time with the set.
+func (s Set) Overlaps(i Interval) bool {
+	for _, r := range s {
+		if r.Overlaps(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len is the total number of minutes in the set.
+func (s Set) Len() int {
+	total := 0
+	for _, i := range s {
+		total += i.Len()
+	}
+	return total
+}
@@ -0,0 +1,154 @@
+package scheduling
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []Interval
+		want Set
+	}{
+		{name: "nil", in: nil, want: Set{}},
+		{name: "drops empty", in: []Interval{{600, 600}, {700, 650}}, want: Set{}},
+		{name: "sorts", in: []Interval{{700, 760}, {540, 600}}, want: Set{{540, 600}, {700, 760}}},
+		{name: "merges overlapping", in: []Interval{{540, 660}, {600, 720}}, want: Set{{540, 720}}},
+		{name: "merges touching", in: []Interval{{540, 600}, {600, 660}}, want: Set{{540, 660}}},
+		{name: "keeps contained", in: []Interval{{540, 720}, {600, 630}}, want: Set{{540, 720}}},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Normalize(%v) = %v, want %v", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSetOperations(t *testing.T) {
+	a := Set{{540, 720}, {780, 1020}} // 09:00-12:00, 13:00-17:00
+	b := Set{{600, 660}, {700, 840}}  // 10:00-11:00, 11:40-14:00
+
+	if got, want := Union(a, b), (Set{{540, 1020}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Union = %v, want %v", got, want)
+	}
+	if got, want := Subtract(a, b), (Set{{540, 600}, {660, 700}, {840, 1020}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Subtract = %v, want %v", got, want)
+	}
+	if got, want := Intersect(a, b), (Set{{600, 660}, {700, 720}, {780, 840}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Intersect = %v, want %v", got, want)
+	}
+}
+
+// The set operations must agree with the same operations on the minutes of the
+// day, and always return normalized sets.
+func TestSetOperationsProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for n := 0; n < 2000; n++ {
+		a, b := randomIntervals(rng), randomIntervals(rng)
+		ma, mb := minutes(Normalize(a)), minutes(Normalize(b))
+
+		checks := []struct {
+			name string
+			got  Set
+			want [DayMinutes]bool
+		}{
+			{"Normalize", Normalize(a), ma},
+			{"Union", Union(a, b), or(ma, mb)},
+			{"Subtract", Subtract(a, b), andNot(ma, mb)},
+			{"Intersect", Intersect(a, b), and(ma, mb)},
+		}
+		for _, c := range checks {
+			if !normalized(c.got) {
+				t.Fatalf("%s(%v, %v) = %v is not normalized", c.name, a, b, c.got)
+			}
+			if minutes(c.got) != c.want {
+				t.Fatalf("%s(%v, %v) = %v, want %v", c.name, a, b, c.got, fromMinutes(c.want))
+			}
+		}
+
+		if got := Normalize(Normalize(a)); !reflect.DeepEqual(got, Normalize(a)) {
+			t.Fatalf("Normalize is not idempotent on %v: %v", a, got)
+		}
+		if Union(a, b).Len() != Subtract(a, b).Len()+Intersect(a, b).Len()+Subtract(b, a).Len() {
+			t.Fatalf("Union(%v, %v) does not split into a-b, a∩b and b-a", a, b)
+		}
+	}
+}
+
+func TestSetCoversAndOverlaps(t *testing.T) {
+	s := Normalize([]Interval{{540, 600}, {600, 660}, {720, 780}})
+	if !s.Covers(Interval{570, 630}) {
+		t.Error("Covers must see across touching intervals once normalized")
+	}
+	if s.Covers(Interval{650, 730}) {
+		t.Error("Covers must not span a gap")
+	}
+	if s.Overlaps(Interval{660, 720}) {
+		t.Error("the gap 11:00-12:00 overlaps nothing")
+	}
+	if !s.Overlaps(Interval{650, 730}) {
+		t.Error("Overlaps must see a partial overlap")
+	}
+}
+
+// randomIntervals returns up to five intervals within the day, some of them empty.
+func randomIntervals(rng *rand.Rand) []Interval {
+	intervals := make([]Interval, rng.Intn(6))
+	for i := range intervals {
+		start := rng.Intn(DayMinutes)
+		intervals[i] = Interval{Start: start, End: start + rng.Intn(DayMinutes-start+1)}
+	}
+	return intervals
+}
+
+func minutes(s Set) [DayMinutes]bool {
+	var m [DayMinutes]bool
+	for _, i := range s {
+		for minute := i.Start; minute < i.End; minute++ {
+			m[minute] = true
+		}
+	}
+	return m
+}
+
+func fromMinutes(m [DayMinutes]bool) Set {
+	var intervals []Interval
+	for minute, in := range m {
+		if in {
+			intervals = append(intervals, Interval{Start: minute, End: minute + 1})
+		}
+	}
+	return Normalize(intervals)
+}
+
+func normalized(s Set) bool {
+	for i, r := range s {
+		if r.Empty() || (i > 0 && r.Start <= s[i-1].End) {
+			return false
+		}
+	}
+	return true
+}
+
+func or(a, b [DayMinutes]bool) (m [DayMinutes]bool) {
+	for i := range m {
+		m[i] = a[i] || b[i]
+	}
+	return m
+}
+
+func and(a, b [DayMinutes]bool) (m [DayMinutes]bool) {
+	for i := range m {
+		m[i] = a[i] && b[i]
+	}
+	return m
+}
+
+func andNot(a, b [DayMinutes]bool) (m [DayMinutes]bool) {
+	for i := range m {
+		m[i] = a[i] && !b[i]
+	}
+	return m
+}
@@ -0,0 +1,40 @@
+package scheduling
+
+// TileOptions control how free time is cut into slots.
+type TileOptions struct {
+	Duration int // Minutes per slot; required
+	Gap      int // Minutes kept free between consecutive slots, e.g. the buffers
+	Align    int // If set, slots start on multiples of Align minutes after midnight
+	Cutoff   int // Slots starting before this minute are dropped, e.g. within the minimum notice today
+}
+
+// Tile cuts each interval of the set into back-to-back slots of Duration minutes
+// separated by Gap, starting at the interval's start or the next aligned minute.
+// Slots never extend past their interval. Slots starting before Cutoff are
+// dropped without shifting the rest, so the grid does not move as time passes.
+func Tile(s Set, opts TileOptions) []Interval {
+	if opts.Duration <= 0 {
+		return nil
+	}
+	var slots []Interval
+	for _, i := range Normalize(s) {
+		for start := align(i.Start, opts.Align); start+opts.Duration <= i.End; start = align(start+opts.Duration+opts.Gap, opts.Align) {
+			if start >= opts.Cutoff {
+				slots = append(slots, Interval{Start: start, End: start + opts.Duration})
+			}
+		}
+	}
+	return slots
+}
+
+// align rounds minute up to the next multiple of step, also for minutes before
+// midnight such as a buffer widened start; a step of 0 or less leaves it as it is.
+func align(minute, step int) int {
+	if step <= 0 {
+		return minute
+	}
+	if r := ((minute % step) + step) % step; r != 0 {
+		return minute + step - r
+	}
+	return minute
+}
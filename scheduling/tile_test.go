@@ -0,0 +1,107 @@
+package scheduling
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestTile(t *testing.T) {
+	day := Set{{540, 720}} // 09:00-12:00
+	tests := []struct {
+		name string
+		set  Set
+		opts TileOptions
+		want []Interval
+	}{
+		{name: "no duration", set: day, opts: TileOptions{}, want: nil},
+		{name: "back to back", set: day, opts: TileOptions{Duration: 60},
+			want: []Interval{{540, 600}, {600, 660}, {660, 720}}},
+		{name: "gap", set: day, opts: TileOptions{Duration: 50, Gap: 10},
+			want: []Interval{{540, 590}, {600, 650}, {660, 710}}},
+		{name: "never past the interval", set: day, opts: TileOptions{Duration: 70},
+			want: []Interval{{540, 610}, {610, 680}}},
+		{name: "aligned start", set: Set{{545, 720}}, opts: TileOptions{Duration: 30, Align: 30},
+			want: []Interval{{570, 600}, {600, 630}, {630, 660}, {660, 690}, {690, 720}}},
+		{name: "aligned after gap", set: day, opts: TileOptions{Duration: 45, Gap: 5, Align: 30},
+			want: []Interval{{540, 585}, {600, 645}, {660, 705}}},
+		{name: "cutoff keeps the grid", set: day, opts: TileOptions{Duration: 60, Cutoff: 550},
+			want: []Interval{{600, 660}, {660, 720}}},
+		{name: "cutoff on a slot start", set: day, opts: TileOptions{Duration: 60, Cutoff: 600},
+			want: []Interval{{600, 660}, {660, 720}}},
+		{name: "each interval on its own", set: Set{{540, 600}, {630, 700}}, opts: TileOptions{Duration: 30},
+			want: []Interval{{540, 570}, {570, 600}, {630, 660}, {660, 690}}},
+		{name: "before midnight", set: Set{{-30, 120}}, opts: TileOptions{Duration: 60, Align: 60},
+			want: []Interval{{0, 60}, {60, 120}}},
+	}
+	for _, tt := range tests {
+		if got := Tile(tt.set, tt.opts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Tile(%v, %+v) = %v, want %v", tt.name, tt.set, tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestAlign(t *testing.T) {
+	tests := []struct{ minute, step, want int }{
+		{0, 60, 0},
+		{1, 60, 60},
+		{60, 60, 60},
+		{61, 15, 75},
+		{-5, 60, 0},
+		{-60, 60, -60},
+		{-61, 60, -60},
+		{17, 0, 17},
+		{17, -5, 17},
+	}
+	for _, tt := range tests {
+		if got := align(tt.minute, tt.step); got != tt.want {
+			t.Errorf("align(%d, %d) = %d, want %d", tt.minute, tt.step, got, tt.want)
+		}
+	}
+}
+
+// Every slot lies inside the set, has the requested length, starts on the grid
+// and at or after the cutoff, and keeps the gap to the slot before it in the same
+// interval.
+func TestTileProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for n := 0; n < 2000; n++ {
+		set := Normalize(randomIntervals(rng))
+		opts := TileOptions{
+			Duration: 1 + rng.Intn(120),
+			Gap:      rng.Intn(30),
+			Align:    []int{0, 5, 15, 30, 60}[rng.Intn(5)],
+			Cutoff:   rng.Intn(DayMinutes),
+		}
+		slots := Tile(set, opts)
+		for i, slot := range slots {
+			if slot.Len() != opts.Duration {
+				t.Fatalf("Tile(%v, %+v): %v is not %d minutes", set, opts, slot, opts.Duration)
+			}
+			if !set.Covers(slot) {
+				t.Fatalf("Tile(%v, %+v): %v leaves the set", set, opts, slot)
+			}
+			if opts.Align > 0 && slot.Start%opts.Align != 0 {
+				t.Fatalf("Tile(%v, %+v): %v is off the grid", set, opts, slot)
+			}
+			if slot.Start < opts.Cutoff {
+				t.Fatalf("Tile(%v, %+v): %v starts before the cutoff", set, opts, slot)
+			}
+			if i > 0 && slot.Start < slots[i-1].End+opts.Gap && set.Covers(Interval{Start: slots[i-1].Start, End: slot.End}) {
+				t.Fatalf("Tile(%v, %+v): %v is closer than the gap to %v", set, opts, slot, slots[i-1])
+			}
+		}
+
+		// The cutoff only drops slots; it never moves the others
+		all := Tile(set, TileOptions{Duration: opts.Duration, Gap: opts.Gap, Align: opts.Align})
+		var kept []Interval
+		for _, slot := range all {
+			if slot.Start >= opts.Cutoff {
+				kept = append(kept, slot)
+			}
+		}
+		if !reflect.DeepEqual(slots, kept) {
+			t.Fatalf("Tile(%v, %+v) = %v, want %v", set, opts, slots, kept)
+		}
+	}
+}
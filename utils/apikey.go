@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// APIKeyPrefix starts every API key so it can be told apart from a JWT.
+const APIKeyPrefix = "taas_"
+
+// apiKeyLookupLength is the number of hex characters of the public lookup prefix.
+const apiKeyLookupLength = 12
+
+// GenerateAPIKey returns a new raw API key and its public lookup prefix.
+func GenerateAPIKey() (key string, lookup string, err error) {
+	b := make([]byte, apiKeyLookupLength/2)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	lookup = hex.EncodeToString(b)
+
+	secret, err := GenerateOpaqueToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return APIKeyPrefix + lookup + "_" + secret, lookup, nil
+}
+
+// IsAPIKey reports whether a credential looks like an API key.
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// APIKeyLookup extracts the lookup prefix from a raw API key.
+func APIKeyLookup(key string) (string, bool) {
+	rest := strings.TrimPrefix(key, APIKeyPrefix)
+	if rest == key || len(rest) < apiKeyLookupLength+2 || rest[apiKeyLookupLength] != '_' {
+		return "", false
+	}
+	return rest[:apiKeyLookupLength], true
+}
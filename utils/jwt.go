@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"time"
 
 	"os"
@@ -8,14 +9,79 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func GenerateToken(email string) (string, error) {
+// AccessTokenTTL is the lifetime of an access token. Sessions are kept alive
+// with refresh tokens, so access tokens are intentionally short-lived.
+const AccessTokenTTL = 15 * time.Minute
+
+// Token purposes. Access tokens have no purpose; challenge tokens can only be
+// used to finish a specific step of the sign-in flow.
+const (
+	PurposeMFA       = "mfa"        // Password accepted, a TOTP or recovery code is still required
+	PurposeMFAEnroll = "mfa_enroll" // Password accepted, the account must enroll in TOTP first
+)
+
+// Claims is the payload carried by every access token issued by the API.
+type Claims struct {
+	UserID      string `json:"user_id"`
+	Email       string `json:"email"`
+	AccountType string `json:"account_type"`
+	Purpose     string `json:"purpose,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// GenerateToken issues a signed access token for the given account.
+func GenerateToken(userID, email, accountType string) (string, error) {
 	secret := os.Getenv("JWT_SECRET")
 
-	claims := jwt.MapClaims{
-		"email": email,
-		"exp":   time.Now().Add(time.Hour * 72).Unix(), // Token valid for 72 hours
+	claims := Claims{
+		UserID:      userID,
+		Email:       email,
+		AccountType: accountType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
+		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(secret))
 }
+
+// GenerateChallengeToken issues a short-lived token that only proves the password
+// step of a sign-in for the given purpose.
+func GenerateChallengeToken(userID, email, accountType, purpose string, ttl time.Duration) (string, error) {
+	secret := os.Getenv("JWT_SECRET")
+
+	claims := Claims{
+		UserID:      userID,
+		Email:       email,
+		AccountType: accountType,
+		Purpose:     purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secret))
+}
+
+// ParseToken verifies the signature and expiry of an access token and returns its claims.
+func ParseToken(tokenString string) (*Claims, error) {
+	secret := os.Getenv("JWT_SECRET")
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.UserID == "" {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}